@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+const (
+	accountActive = "active"
+	// accountSuspended users can't sign in until suspended_until passes,
+	// after which they are treated as active again.
+	accountSuspended = "suspended"
+	// accountShadowBanned users can use the API as normal, but nobody else
+	// sees their chirps and their direct messages are refused. A report can
+	// suspend them as well, through suspended_until, without lifting it.
+	accountShadowBanned = "shadow_banned"
+	accountBanned       = "banned"
+)
+
+var errAccountBanned = errors.New("Account banned")
+
+// accountRestriction reports why user may not authenticate, or nil if they
+// may. A shadow-banned user can be suspended as well, so suspended_until is
+// honoured whatever the state.
+func accountRestriction(user database.User) error {
+	if user.AccountState == accountBanned {
+		return errAccountBanned
+	}
+	if user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now().UTC()) {
+		return fmt.Errorf("Account suspended until %s", user.SuspendedUntil.Time.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// setAccountState moves user to a new state, suspended until the given
+// time if it is set, and audits the change. Signing a user out is part of
+// suspending or banning them, so their refresh tokens are revoked too;
+// access tokens are rejected by authenticatedUser. Pass a transaction's
+// queries to make the change part of a larger one.
+func setAccountState(ctx context.Context, q *database.Queries, user database.User, changedBy uuid.UUID, state string, until sql.NullTime, reason string) (database.User, error) {
+	updated, err := q.SetAccountState(ctx, database.SetAccountStateParams{
+		ID:             user.ID,
+		AccountState:   state,
+		SuspendedUntil: until,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = q.RecordAccountStateChange(ctx, database.RecordAccountStateChangeParams{
+		UserID:         user.ID,
+		ChangedBy:      changedBy,
+		FromState:      user.AccountState,
+		ToState:        state,
+		SuspendedUntil: until,
+		Reason:         reason,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if until.Valid || state == accountBanned {
+		if err := q.RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+			return database.User{}, err
+		}
+	}
+	return updated, nil
+}
+
+// hideRestrictedChirps drops chirps the viewer shouldn't see: those by
+// shadow-banned or banned authors (except a shadow-banned author's own),
+// by anyone the viewer has blocked or muted, and by anyone who has blocked
+// the viewer. Feeds filtered this way can come back shorter than the page
+// size asked for; clients already page by cursor, so that only costs an
+// extra request.
+func (cfg *apiConfig) hideRestrictedChirps(ctx context.Context, viewerID uuid.UUID, dbChirps []database.Chirp) ([]database.Chirp, error) {
+	authorIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		authorIDs = append(authorIDs, dbChirp.UserID)
+	}
+	if len(authorIDs) == 0 {
+		return dbChirps, nil
+	}
+
+	hiddenIDs, err := cfg.db.GetHiddenAuthors(ctx, database.GetHiddenAuthorsParams{
+		Ids:      authorIDs,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(hiddenIDs) == 0 {
+		return dbChirps, nil
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, id := range hiddenIDs {
+		hidden[id] = true
+	}
+
+	visible := []database.Chirp{}
+	for _, dbChirp := range dbChirps {
+		if !hidden[dbChirp.UserID] {
+			visible = append(visible, dbChirp)
+		}
+	}
+	return visible, nil
+}
+
+type AccountStateChange struct {
+	ID             uuid.UUID  `json:"id"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ChangedBy      uuid.UUID  `json:"changed_by"`
+	FromState      string     `json:"from_state"`
+	ToState        string     `json:"to_state"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+	Reason         string     `json:"reason"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type AccountState struct {
+	UserID         uuid.UUID  `json:"user_id"`
+	State          string     `json:"state"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+func accountStateFromDB(user database.User) AccountState {
+	state := AccountState{UserID: user.ID, State: user.AccountState}
+	if user.SuspendedUntil.Valid {
+		state.SuspendedUntil = &user.SuspendedUntil.Time
+	}
+	return state
+}
+
+func (cfg *apiConfig) handlerSetAccountState(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		State          string    `json:"state"`
+		SuspendedUntil time.Time `json:"suspended_until"`
+		Reason         string    `json:"reason"`
+	}
+
+	admin, ok := cfg.requireRole(w, r, roleAdmin)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	if userID == admin.ID {
+		respondWithError(w, http.StatusBadRequest, "You can't change your own account state", nil)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	switch params.State {
+	case accountActive, accountShadowBanned, accountBanned:
+	case accountSuspended:
+		if !params.SuspendedUntil.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "suspended_until must be in the future", nil)
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "state must be active, suspended, shadow_banned or banned", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update account state", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	user, err := q.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	until := sql.NullTime{Time: params.SuspendedUntil.UTC(), Valid: params.State == accountSuspended}
+	updated, err := setAccountState(r.Context(), q, user, admin.ID, params.State, until, params.Reason)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update account state", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update account state", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, accountStateFromDB(updated))
+}
+
+func (cfg *apiConfig) handlerAccountStateChanges(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	dbChanges, err := cfg.db.GetAccountStateChanges(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve account state changes", err)
+		return
+	}
+
+	changes := []AccountStateChange{}
+	for _, c := range dbChanges {
+		change := AccountStateChange{
+			ID:        c.ID,
+			UserID:    c.UserID,
+			ChangedBy: c.ChangedBy,
+			FromState: c.FromState,
+			ToState:   c.ToState,
+			Reason:    c.Reason,
+			CreatedAt: c.CreatedAt,
+		}
+		if c.SuspendedUntil.Valid {
+			change.SuspendedUntil = &c.SuspendedUntil.Time
+		}
+		changes = append(changes, change)
+	}
+	respondWithJSON(w, http.StatusOK, changes)
+}
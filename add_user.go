@@ -131,6 +131,11 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    if err := accountRestriction(user); err != nil {
+        respondWithError(w, http.StatusForbidden, err.Error(), err)
+        return
+    }
+
     userToken, err := auth.MakeJWT(user.ID, cfg.JwtSecret, expiryDuration)
     if err != nil {
         respondWithError(w, http.StatusUnauthorized, "Could not create token", nil)
@@ -199,6 +204,16 @@ func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    user, err := cfg.db.GetUserByID(r.Context(), refreshedUser.UserID)
+    if err != nil {
+        respondWithError(w, http.StatusInternalServerError, "Could not find user in database", err)
+        return
+    }
+    if err := accountRestriction(user); err != nil {
+        respondWithError(w, http.StatusForbidden, err.Error(), err)
+        return
+    }
+
     hour, _ := time.ParseDuration("1h")
 
     userToken, err := auth.MakeJWT(refreshedUser.UserID, cfg.JwtSecret, hour)
@@ -236,13 +251,7 @@ func (cfg *apiConfig) handlerChangePassword(w http.ResponseWriter, r *http.Reque
         Password    string  `json:"password"`  
     }
 
-    accessToken, err := auth.GetBearerToken(r.Header)
-    if err != nil {
-        respondWithError(w, http.StatusUnauthorized, "No token found in header", err)
-        return 
-    }
-
-    UserToUpdate, err := auth.ValidateJWT(accessToken, cfg.JwtSecret)
+    UserToUpdate, err := cfg.authenticatedUserID(r)
     if err != nil {
         respondWithError(w, http.StatusUnauthorized, "Could not validate token", err)
         return
@@ -278,54 +287,6 @@ func (cfg *apiConfig) handlerChangePassword(w http.ResponseWriter, r *http.Reque
 
 }
 
-func (cfg *apiConfig) handlerUpgradeAccount(w http.ResponseWriter, r *http.Request) {
-
-    type parameters struct {
-        Event   string      `json:"event"`
-        Data struct {
-            UserID string `json:"user_id"`
-        } `json:"data"`
-        }
-
-    apiKey, err := auth.GetAPIKey(r.Header)
-    if err != nil {
-        respondWithError(w, http.StatusUnauthorized,"No key found in header", err)
-        return
-    }
-
-    if apiKey != cfg.polkaKey {
-        respondWithError(w, http.StatusUnauthorized,"Incorrect key found in header", err)
-        return
-    }
-
-    decoder := json.NewDecoder(r.Body)  // Fix: use r.Body instead of r.Email
-    params := parameters{}
-    err = decoder.Decode(&params)
-    if err != nil {
-        respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
-        return
-    }
-    
-    if params.Event != "user.upgraded" {
-        w.WriteHeader(http.StatusNoContent)
-        return
-    }
-
-    parsedUser, err := uuid.Parse(params.Data.UserID)
-    if err != nil {
-		respondWithError(w, http.StatusInternalServerError,"Could not parse id", err)
-		return
-	}
-
-    err = cfg.db.UpgradeUser(r.Context(), parsedUser)
-    if err != nil {
-        respondWithError(w, http.StatusNotFound, "Could not find user", err)
-        return
-    }
-
-    w.WriteHeader(http.StatusNoContent)
-}
-
 func (cfg *apiConfig) handlerShowUsers(w http.ResponseWriter, r *http.Request) {
     users, err := cfg.db.GetAllUsers(r.Context())
     if err != nil {
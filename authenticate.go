@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/auth"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+// authenticatedUser returns the user the request's bearer JWT was issued
+// to. Tokens belonging to suspended or banned accounts are rejected even if
+// they haven't expired yet.
+func (cfg *apiConfig) authenticatedUser(r *http.Request) (database.User, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return database.User{}, err
+	}
+	return cfg.userFromToken(r.Context(), token)
+}
+
+// userFromToken is authenticatedUser for tokens that don't arrive in an
+// Authorization header.
+func (cfg *apiConfig) userFromToken(ctx context.Context, token string) (database.User, error) {
+	userID, err := auth.ValidateJWT(token, cfg.JwtSecret)
+	if err != nil {
+		return database.User{}, err
+	}
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := accountRestriction(user); err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+// authenticatedUserID is authenticatedUser for handlers that only need the
+// ID.
+func (cfg *apiConfig) authenticatedUserID(r *http.Request) (uuid.UUID, error) {
+	user, err := cfg.authenticatedUser(r)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	return user.ID, nil
+}
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// requireRole authenticates the request and checks the user holds one of
+// the given roles, writing the error response itself when they don't.
+// Admins pass every role check.
+func (cfg *apiConfig) requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (database.User, bool) {
+	user, err := cfg.authenticatedUser(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return database.User{}, false
+	}
+
+	if user.Role == roleAdmin {
+		return user, true
+	}
+	for _, role := range roles {
+		if user.Role == role {
+			return user, true
+		}
+	}
+
+	respondWithError(w, http.StatusForbidden, "You don't have permission to do that", nil)
+	return database.User{}, false
+}
+
+// optionalUserID is authenticatedUserID for endpoints that also serve
+// anonymous requests. It returns uuid.Nil when no Authorization header was
+// sent, and an error only for a token that is present but invalid.
+func (cfg *apiConfig) optionalUserID(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+	return cfg.authenticatedUserID(r)
+}
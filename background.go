@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/jobs"
+)
+
+const (
+	jobPruneFailedJobs = "jobs.prune"
+	// failedJobRetention is how long permanently failed jobs are kept for
+	// inspection before they are deleted.
+	failedJobRetention = 7 * 24 * time.Hour
+)
+
+// registerJobs wires every background job kind to its handler.
+func (cfg *apiConfig) registerJobs(pool *jobs.Pool) {
+	pool.Handle(jobFanOutChirp, cfg.jobFanOutChirp)
+	pool.Handle(jobBackfillFollow, cfg.jobBackfillFollow)
+	pool.Handle(jobRemoveFollow, cfg.jobRemoveFollow)
+	pool.Handle(jobComputeTrends, cfg.jobComputeTrends)
+	pool.Handle(jobPruneRateLimits, cfg.jobPruneRateLimits)
+	pool.Handle(jobPruneFailedJobs, cfg.jobPruneFailedJobs)
+	pool.Handle(jobPublishScheduled, cfg.jobPublishScheduled)
+	pool.Handle(jobCollectMedia, cfg.jobCollectMedia)
+	pool.Handle(jobFetchLinkPreviews, cfg.jobFetchLinkPreviews)
+	pool.Handle(jobNotify, cfg.jobNotify)
+	pool.Handle(jobNotifyChirp, cfg.jobNotifyChirp)
+	pool.Handle(jobDispatchWebhooks, cfg.jobDispatchWebhooks)
+	pool.Handle(jobDeliverWebhook, cfg.jobDeliverWebhook)
+	pool.Handle(jobExpireSubscriptions, cfg.jobExpireSubscriptions)
+}
+
+// enqueue schedules a background job. The request that triggered it has
+// already succeeded, so a failure here is logged rather than returned.
+func (cfg *apiConfig) enqueue(ctx context.Context, kind string, payload any, opts jobs.Options) {
+	if err := cfg.jobs.Enqueue(ctx, kind, payload, opts); err != nil {
+		log.Printf("Couldn't enqueue %s job: %v", kind, err)
+	}
+}
+
+// jobPruneFailedJobs deletes jobs that failed permanently long enough ago
+// that nobody is going to look at them.
+func (cfg *apiConfig) jobPruneFailedJobs(ctx context.Context, _ json.RawMessage) error {
+	pruned, err := cfg.jobs.PruneFailed(ctx, time.Now().UTC().Add(-failedJobRetention))
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		log.Printf("Pruned %d failed jobs", pruned)
+	}
+	return nil
+}
+
+// afterChirpCreated runs the asynchronous work that follows a new chirp
+// becoming visible.
+func (cfg *apiConfig) afterChirpCreated(ctx context.Context, chirp database.Chirp) {
+	if err := enqueueChirpJobs(ctx, cfg.jobs, chirp); err != nil {
+		log.Printf("Couldn't enqueue jobs for chirp %s: %v", chirp.ID, err)
+	}
+	cfg.publishChirpCreated(ctx, chirp)
+}
+
+// enqueueChirpJobs queues the fan-out, notifications and link previews for
+// a chirp that has become visible. Given a transaction's queue, the jobs
+// commit or roll back with the chirp.
+func enqueueChirpJobs(ctx context.Context, queue *jobs.Queue, chirp database.Chirp) error {
+	if err := queue.Enqueue(ctx, jobFanOutChirp, fanOutPayload{ChirpID: chirp.ID}, jobs.Options{}); err != nil {
+		return err
+	}
+	if err := queue.Enqueue(ctx, jobNotifyChirp, fanOutPayload{ChirpID: chirp.ID}, jobs.Options{}); err != nil {
+		return err
+	}
+	if len(chirpLinks(chirp.Body)) > 0 {
+		return queue.Enqueue(ctx, jobFetchLinkPreviews, linkPreviewPayload{ChirpID: chirp.ID}, jobs.Options{
+			DedupeKey:   jobFetchLinkPreviews + ":" + chirp.ID.String(),
+			MaxAttempts: 3,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/entities"
+	"workspace/github.com/Benjysparks/chirpy/internal/jobs"
+)
+
+// RelatedUser is an entry in a block or mute list.
+type RelatedUser struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// blockedEitherWay reports whether either user has blocked the other.
+func (cfg *apiConfig) blockedEitherWay(ctx context.Context, a, b uuid.UUID) (bool, error) {
+	return cfg.db.IsBlockedEitherWay(ctx, database.IsBlockedEitherWayParams{UserA: a, UserB: b})
+}
+
+// blockedMentions returns the usernames mentioned in body whose owners have
+// blocked authorID.
+func (cfg *apiConfig) blockedMentions(ctx context.Context, authorID uuid.UUID, body string) ([]string, error) {
+	usernames := []string{}
+	for _, entity := range entities.Parse(body) {
+		if entity.Kind == entities.Mention {
+			usernames = append(usernames, entities.NormalizeUsername(entity.Text))
+		}
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	return cfg.db.GetBlockersByUsernames(ctx, database.GetBlockersByUsernamesParams{
+		BlockedID: authorID,
+		Usernames: usernames,
+	})
+}
+
+// relationshipTarget authenticates the request and parses the {id} of the
+// account being blocked or muted, writing the error response itself on
+// failure.
+func (cfg *apiConfig) relationshipTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't do that to yourself", nil)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+	return userID, targetID, true
+}
+
+// handlerBlock blocks a user and removes any follow between the two
+// accounts, in both directions.
+func (cfg *apiConfig) handlerBlock(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not find user", err)
+		return
+	}
+
+	_, err := cfg.db.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+		return
+	}
+
+	for _, follow := range []followPayload{
+		{FollowerID: userID, FolloweeID: targetID},
+		{FollowerID: targetID, FolloweeID: userID},
+	} {
+		removed, err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+			FollowerID: follow.FollowerID,
+			FolloweeID: follow.FolloweeID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't block user", err)
+			return
+		}
+		if removed > 0 {
+			cfg.enqueue(r.Context(), jobRemoveFollow, follow, jobs.Options{})
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnblock(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := cfg.db.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unblock user", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "You haven't blocked this user", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), targetID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not find user", err)
+		return
+	}
+
+	_, err := cfg.db.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't mute user", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnmute(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	removed, err := cfg.db.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unmute user", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "You haven't muted this user", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// relatedUsersPage fetches one page of a user's block or mute list.
+type relatedUsersPage func(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]RelatedUser, error)
+
+func (cfg *apiConfig) blockedUsersPage(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]RelatedUser, error) {
+	rows, err := cfg.db.GetBlockedUsers(ctx, database.GetBlockedUsersParams{
+		BlockerID: userID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	users := []RelatedUser{}
+	for _, row := range rows {
+		users = append(users, RelatedUser{ID: row.ID, Username: row.Username, CreatedAt: row.CreatedAt})
+	}
+	return users, nil
+}
+
+func (cfg *apiConfig) mutedUsersPage(ctx context.Context, userID uuid.UUID, limit, offset int32) ([]RelatedUser, error) {
+	rows, err := cfg.db.GetMutedUsers(ctx, database.GetMutedUsersParams{
+		MuterID: userID,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	users := []RelatedUser{}
+	for _, row := range rows {
+		users = append(users, RelatedUser{ID: row.ID, Username: row.Username, CreatedAt: row.CreatedAt})
+	}
+	return users, nil
+}
+
+func (cfg *apiConfig) handlerBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	cfg.listRelatedUsers(w, r, cfg.blockedUsersPage)
+}
+
+func (cfg *apiConfig) handlerMutedUsers(w http.ResponseWriter, r *http.Request) {
+	cfg.listRelatedUsers(w, r, cfg.mutedUsersPage)
+}
+
+func (cfg *apiConfig) handlerExportBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	cfg.exportRelatedUsers(w, r, "blocked.csv", cfg.blockedUsersPage)
+}
+
+func (cfg *apiConfig) handlerExportMutedUsers(w http.ResponseWriter, r *http.Request) {
+	cfg.exportRelatedUsers(w, r, "muted.csv", cfg.mutedUsersPage)
+}
+
+func (cfg *apiConfig) listRelatedUsers(w http.ResponseWriter, r *http.Request, page relatedUsersPage) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	users, err := page(r.Context(), userID, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve users", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+// exportRelatedUsers writes a whole block or mute list as CSV, so it can be
+// imported elsewhere or kept as a backup.
+func (cfg *apiConfig) exportRelatedUsers(w http.ResponseWriter, r *http.Request, filename string, page relatedUsersPage) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	all := []RelatedUser{}
+	for offset := int32(0); ; offset += maxPageSize {
+		users, err := page(r.Context(), userID, maxPageSize, offset)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't export users", err)
+			return
+		}
+		all = append(all, users...)
+		if len(users) < maxPageSize {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"user_id", "username", "created_at"})
+	for _, user := range all {
+		out.Write([]string{user.ID.String(), user.Username, user.CreatedAt.Format(time.RFC3339)})
+	}
+	out.Flush()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+// Bookmarks are private: nobody but the user who saved a chirp can see that
+// they did, including its author.
+
+func (cfg *apiConfig) handlerBookmark(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpsByID(r.Context(), chirpID)
+	if err == nil && chirp.Status != chirpStatusPublished {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		var visible []database.Chirp
+		visible, err = cfg.hideRestrictedChirps(r.Context(), userID, []database.Chirp{chirp})
+		if err == nil && len(visible) == 0 {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	err = cfg.db.BookmarkChirp(r.Context(), database.BookmarkChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't bookmark chirp", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	removed, err := cfg.db.RemoveBookmark(r.Context(), database.RemoveBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove bookmark", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "You haven't bookmarked this chirp", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerBookmarks lists the caller's bookmarked chirps, most recently
+// bookmarked first. It pages by offset rather than by cursor because the
+// order follows when chirps were bookmarked, not when they were posted.
+func (cfg *apiConfig) handlerBookmarks(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetBookmarkedChirps(r.Context(), database.GetBookmarkedChirpsParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve bookmarks", err)
+		return
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve bookmarks", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/entities"
+)
+
+// ChirpEntities lets clients render hashtags and mentions as links. Offsets
+// are in Unicode code points, end exclusive, and include the # or @.
+type ChirpEntities struct {
+	Hashtags []HashtagEntity `json:"hashtags"`
+	Mentions []MentionEntity `json:"mentions"`
+}
+
+type HashtagEntity struct {
+	Tag   string `json:"tag"`
+	Start int32  `json:"start"`
+	End   int32  `json:"end"`
+}
+
+type MentionEntity struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Start    int32     `json:"start"`
+	End      int32     `json:"end"`
+}
+
+func emptyEntities() ChirpEntities {
+	return ChirpEntities{Hashtags: []HashtagEntity{}, Mentions: []MentionEntity{}}
+}
+
+// saveChirpEntities records the hashtags and mentions in a newly created
+// chirp. Mentions of usernames that don't exist are dropped.
+func saveChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	found := entities.Parse(chirp.Body)
+
+	usernames := []string{}
+	for _, entity := range found {
+		if entity.Kind == entities.Mention {
+			usernames = append(usernames, entities.NormalizeUsername(entity.Text))
+		}
+	}
+	userIDs := map[string]uuid.UUID{}
+	if len(usernames) > 0 {
+		users, err := q.GetUsersByUsernames(ctx, usernames)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			userIDs[entities.NormalizeUsername(user.Username)] = user.ID
+		}
+	}
+
+	for _, entity := range found {
+		switch entity.Kind {
+		case entities.Hashtag:
+			err := q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+				ChirpID:    chirp.ID,
+				Tag:        entities.NormalizeTag(entity.Text),
+				StartIndex: int32(entity.Start),
+				EndIndex:   int32(entity.End),
+				CreatedAt:  chirp.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		case entities.Mention:
+			userID, ok := userIDs[entities.NormalizeUsername(entity.Text)]
+			if !ok {
+				continue
+			}
+			err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+				ChirpID:    chirp.ID,
+				UserID:     userID,
+				StartIndex: int32(entity.Start),
+				EndIndex:   int32(entity.End),
+				CreatedAt:  chirp.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadChirpEntities fetches the stored entities for a batch of chirps.
+func (cfg *apiConfig) loadChirpEntities(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID]ChirpEntities, error) {
+	byChirp := map[uuid.UUID]ChirpEntities{}
+	if len(chirpIDs) == 0 {
+		return byChirp, nil
+	}
+
+	hashtags, err := cfg.db.GetHashtagsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := cfg.db.GetMentionsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range hashtags {
+		e, ok := byChirp[tag.ChirpID]
+		if !ok {
+			e = emptyEntities()
+		}
+		e.Hashtags = append(e.Hashtags, HashtagEntity{Tag: tag.Tag, Start: tag.StartIndex, End: tag.EndIndex})
+		byChirp[tag.ChirpID] = e
+	}
+	for _, mention := range mentions {
+		e, ok := byChirp[mention.ChirpID]
+		if !ok {
+			e = emptyEntities()
+		}
+		e.Mentions = append(e.Mentions, MentionEntity{
+			UserID:   mention.UserID,
+			Username: mention.Username,
+			Start:    mention.StartIndex,
+			End:      mention.EndIndex,
+		})
+		byChirp[mention.ChirpID] = e
+	}
+	return byChirp, nil
+}
+
+func (cfg *apiConfig) handlerHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag", nil)
+		return
+	}
+
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	before, limit, err := cursorPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetChirpsByHashtag(r.Context(), database.GetChirpsByHashtagParams{
+		Tag:      tag,
+		Before:   before,
+		PageSize: limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), viewerID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+func (cfg *apiConfig) handlerMyMentions(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	before, limit, err := cursorPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetChirpsMentioningUser(r.Context(), database.GetChirpsMentioningUserParams{
+		MentionedUserID: userID,
+		Before:          before,
+		PageSize:        limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve mentions", err)
+		return
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve mentions", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
@@ -0,0 +1,114 @@
+// Command fakepolka sends Polka webhooks to a local Chirpy server.
+//
+// Send one event:
+//
+//	fakepolka -event user.upgraded -user <uuid>
+//
+// Replay a recording (JSON lines, or the output of GET /admin/polka/webhooks)
+// with every event delivered twice, in a shuffled order:
+//
+//	fakepolka -replay events.jsonl -duplicates 1 -shuffle
+//
+// POLKA_KEY and POLKA_WEBHOOK_SECRET are read from the environment or .env,
+// as Chirpy reads them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"workspace/github.com/Benjysparks/chirpy/internal/fakepolka"
+)
+
+func main() {
+	godotenv.Load()
+
+	url := flag.String("url", fakepolka.DefaultURL, "Chirpy's Polka webhook URL")
+	apiKey := flag.String("key", os.Getenv("POLKA_KEY"), "API key to send, if any")
+	secret := flag.String("secret", os.Getenv("POLKA_WEBHOOK_SECRET"), "secret to sign with, if any")
+	event := flag.String("event", fakepolka.UserUpgraded, "event to send")
+	user := flag.String("user", "", "user ID the event is about")
+	periodEnd := flag.String("period-end", "", "RFC 3339 end of the paid period, for upgrades and renewals")
+	replay := flag.String("replay", "", "file of recorded events to replay, or - for stdin")
+	duplicates := flag.Int("duplicates", 0, "extra times to send each event")
+	shuffle := flag.Bool("shuffle", false, "deliver events out of order")
+	seed := flag.Uint64("seed", uint64(time.Now().UnixNano()), "seed for -shuffle")
+	attempts := flag.Int("attempts", 1, "delivery attempts per event")
+	retryDelay := flag.Duration("retry-delay", time.Second, "delay between attempts")
+	flag.Parse()
+
+	bodies, err := loadBodies(*replay, *event, *user, *periodEnd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := &fakepolka.Client{URL: *url, APIKey: *apiKey, Secret: *secret}
+	if *apiKey == "" && *secret == "" {
+		log.Print("Neither -key nor -secret is set; Chirpy will reject the requests")
+	}
+	if *shuffle {
+		log.Printf("Shuffling with -seed %d", *seed)
+	}
+
+	failed := 0
+	results := client.Replay(ctx, bodies, fakepolka.Options{
+		Duplicates: *duplicates,
+		Shuffle:    *shuffle,
+		Seed:       *seed,
+		Retry:      fakepolka.RetryPolicy{Attempts: *attempts, Delay: *retryDelay},
+	})
+	for _, result := range results {
+		statuses := ""
+		for _, resp := range result.Responses {
+			statuses += fmt.Sprintf(" %d", resp.StatusCode)
+		}
+		fmt.Printf("%s ->%s\n", result.Body, statuses)
+		if result.Err != nil {
+			failed++
+			fmt.Printf("  %v\n", result.Err)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func loadBodies(replay, event, user, periodEnd string) ([][]byte, error) {
+	if replay != "" {
+		var r io.Reader = os.Stdin
+		if replay != "-" {
+			f, err := os.Open(replay)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		return fakepolka.ReadRecording(r)
+	}
+
+	userID, err := uuid.Parse(user)
+	if err != nil {
+		return nil, fmt.Errorf("-user must be a user ID: %w", err)
+	}
+	e := fakepolka.NewEvent(event, userID)
+	if periodEnd != "" {
+		t, err := time.Parse(time.RFC3339, periodEnd)
+		if err != nil {
+			return nil, fmt.Errorf("-period-end: %w", err)
+		}
+		e = e.WithPeriodEnd(t)
+	}
+	return [][]byte{e.Body()}, nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+const pqUniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/moderation"
+)
+
+// Drafts are chirps with the draft status. They are only visible to their
+// author and skip every posting check until they are published, so their
+// hashtags and mentions aren't extracted until then either.
+
+// draftTarget authenticates the request and parses the {draftID} path
+// value, writing the error response itself on failure.
+func (cfg *apiConfig) draftTarget(w http.ResponseWriter, r *http.Request) (database.User, uuid.UUID, bool) {
+	user, err := cfg.authenticatedUser(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return database.User{}, uuid.UUID{}, false
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return database.User{}, uuid.UUID{}, false
+	}
+	return user, draftID, true
+}
+
+// respondWithOwnChirp writes one of the author's chirps, whatever its status.
+func (cfg *apiConfig) respondWithOwnChirp(w http.ResponseWriter, r *http.Request, code int, chirp database.Chirp) {
+	chirps, err := cfg.expandChirps(r.Context(), chirp.UserID, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+	respondWithJSON(w, code, chirps[0])
+}
+
+func (cfg *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body         string     `json:"body"`
+		QuoteChirpID *uuid.UUID `json:"quote_chirp_id"`
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if !cfg.checkChirpLength(w, r, userID, params.Body) {
+		return
+	}
+
+	quoteOf := uuid.NullUUID{}
+	if params.QuoteChirpID != nil {
+		quoteOf, err = cfg.resolveQuote(r.Context(), userID, *params.QuoteChirpID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find quoted chirp", err)
+			return
+		}
+	}
+
+	draft, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		Body:    params.Body,
+		UserID:  userID,
+		QuoteOf: quoteOf,
+		IsQuote: quoteOf.Valid,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create draft", err)
+		return
+	}
+
+	cfg.respondWithOwnChirp(w, r, http.StatusCreated, draft)
+}
+
+func (cfg *apiConfig) handlerDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetDrafts(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve drafts", err)
+		return
+	}
+
+	drafts, err := cfg.expandChirps(r.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve drafts", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, drafts)
+}
+
+func (cfg *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	user, draftID, ok := cfg.draftTarget(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if !cfg.checkChirpLength(w, r, user.ID, params.Body) {
+		return
+	}
+
+	draft, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:     draftID,
+		UserID: user.ID,
+		Body:   params.Body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find draft", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update draft", err)
+		return
+	}
+
+	cfg.respondWithOwnChirp(w, r, http.StatusOK, draft)
+}
+
+func (cfg *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	user, draftID, ok := cfg.draftTarget(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete draft", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find draft", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerPublishDraft posts a draft, running the same checks as a new chirp.
+// An optional publish_at schedules it instead.
+func (cfg *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	user, draftID, ok := cfg.draftTarget(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+			return
+		}
+	}
+
+	publishAt := sql.NullTime{}
+	if params.PublishAt != nil {
+		if err := validatePublishAt(*params.PublishAt); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		publishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+
+	draft, err := cfg.db.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find draft", err)
+		return
+	}
+
+	// The quoted chirp may have been deleted, hidden or its author may have
+	// blocked this user since the draft was saved.
+	if draft.IsQuote {
+		if !draft.QuoteOf.Valid {
+			respondWithError(w, http.StatusNotFound, "Couldn't find quoted chirp", nil)
+			return
+		}
+		if _, err := cfg.resolveQuote(r.Context(), user.ID, draft.QuoteOf.UUID); err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find quoted chirp", err)
+			return
+		}
+	}
+
+	limits, err := cfg.userEntitlements(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load account limits", err)
+		return
+	}
+	verdict, spamResult, ok := cfg.vetChirp(w, r, user, limits, draft.Body, draft.IsQuote)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.publishDraft(r.Context(), database.PublishDraftParams{
+		ID:        draft.ID,
+		Body:      verdict.Text,
+		Status:    chirpStatusFor(publishAt, verdict),
+		PublishAt: publishAt,
+	}, verdict)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Draft has already been published", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish draft", err)
+		return
+	}
+
+	cfg.recordSpamCheck(r.Context(), user.ID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, spamResult)
+
+	if chirp.Status == chirpStatusPublished {
+		cfg.afterChirpCreated(r.Context(), chirp)
+	}
+
+	cfg.respondWithOwnChirp(w, r, http.StatusOK, chirp)
+}
+
+// publishDraft moves a draft to its new status and extracts its entities in
+// one transaction, mirroring createChirp.
+func (cfg *apiConfig) publishDraft(ctx context.Context, params database.PublishDraftParams, verdict moderation.Result) (database.Chirp, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	chirp, err := q.PublishDraft(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if err := saveChirpEntities(ctx, q, chirp); err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.Status == chirpStatusHeld {
+		err := q.HoldChirp(ctx, database.HoldChirpParams{ChirpID: chirp.ID, Reasons: verdict.Reasons()})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	return chirp, tx.Commit()
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/jobs"
+)
+
+// UserSummary is the public view of an account used in lists.
+type UserSummary struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+type followList struct {
+	Count int64         `json:"count"`
+	Users []UserSummary `json:"users"`
+}
+
+func (cfg *apiConfig) handlerFollow(w http.ResponseWriter, r *http.Request) {
+	followerID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	if followeeID == followerID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), followeeID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not find user", err)
+		return
+	}
+
+	blocked, err := cfg.blockedEitherWay(r.Context(), followerID, followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't follow this user", nil)
+		return
+	}
+
+	added, err := cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+		return
+	}
+
+	if added > 0 {
+		cfg.enqueue(r.Context(), jobBackfillFollow, followPayload{FollowerID: followerID, FolloweeID: followeeID}, jobs.Options{})
+		cfg.enqueue(r.Context(), jobNotify, notificationEvent{
+			RecipientID: followeeID,
+			ActorID:     followerID,
+			Type:        notificationFollow,
+		}, jobs.Options{})
+		cfg.emitWebhookEvent(r.Context(), eventUserFollowed, []uuid.UUID{followerID, followeeID}, struct {
+			FollowerID uuid.UUID `json:"follower_id"`
+			FolloweeID uuid.UUID `json:"followee_id"`
+		}{followerID, followeeID})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnfollow(w http.ResponseWriter, r *http.Request) {
+	followerID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	followeeID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	removed, err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "You don't follow this user", nil)
+		return
+	}
+
+	cfg.enqueue(r.Context(), jobRemoveFollow, followPayload{FollowerID: followerID, FolloweeID: followeeID}, jobs.Options{})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	count, err := cfg.db.CountFollowers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count followers", err)
+		return
+	}
+
+	rows, err := cfg.db.GetFollowers(r.Context(), database.GetFollowersParams{
+		FolloweeID: userID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followers", err)
+		return
+	}
+
+	users := []UserSummary{}
+	for _, row := range rows {
+		users = append(users, UserSummary{ID: row.ID, Username: row.Username})
+	}
+
+	respondWithJSON(w, http.StatusOK, followList{Count: count, Users: users})
+}
+
+func (cfg *apiConfig) handlerFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	count, err := cfg.db.CountFollowing(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count followed accounts", err)
+		return
+	}
+
+	rows, err := cfg.db.GetFollowing(r.Context(), database.GetFollowingParams{
+		FollowerID: userID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followed accounts", err)
+		return
+	}
+
+	users := []UserSummary{}
+	for _, row := range rows {
+		users = append(users, UserSummary{ID: row.ID, Username: row.Username})
+	}
+
+	respondWithJSON(w, http.StatusOK, followList{Count: count, Users: users})
+}
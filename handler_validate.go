@@ -1,28 +1,27 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
 	"github.com/google/uuid"
 	"workspace/github.com/Benjysparks/chirpy/internal/database"
-	"workspace/github.com/Benjysparks/chirpy/internal/auth"
-	"log"
+	"workspace/github.com/Benjysparks/chirpy/internal/entitlements"
+	"workspace/github.com/Benjysparks/chirpy/internal/moderation"
+	"workspace/github.com/Benjysparks/chirpy/internal/spam"
 )
 
 func (cfg *apiConfig) handlerChirpsValidate(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Body     string  `json:"body"`
 		UserID  uuid.UUID  `json:"user_id"`
-	}
-
-	type Chirp struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserID    uuid.UUID `json:"user_id"` 
+		QuoteChirpID *uuid.UUID `json:"quote_chirp_id"`
+		PublishAt *time.Time `json:"publish_at"`
+		AttachmentIDs []uuid.UUID `json:"attachment_ids"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -33,51 +32,212 @@ func (cfg *apiConfig) handlerChirpsValidate(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	author, err := cfg.authenticatedUser(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invalid JWT token", err)
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
 		return
 	}
+	JwtUser := author.ID
 
-	JwtUser, err := auth.ValidateJWT(token, cfg.JwtSecret) 
+	publishAt := sql.NullTime{}
+	if params.PublishAt != nil {
+		if err := validatePublishAt(*params.PublishAt); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		publishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+
+	limits, err := cfg.userEntitlements(r.Context(), JwtUser)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load account limits", err)
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		log.Printf("")
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	if err := limits.CheckAttachments(len(params.AttachmentIDs)); err != nil {
+		respondLimitExceeded(w, err)
+		return
+	}
+	if err := validateAttachmentIDs(params.AttachmentIDs); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	splitChirp := strings.Split(params.Body, " ")
+	verdict, spamResult, ok := cfg.vetChirp(w, r, author, limits, params.Body, params.QuoteChirpID != nil)
+	if !ok {
+		return
+	}
+	cleanedChirp := verdict.Text
 
-	for index, word := range splitChirp {
-		temp := strings.ToLower(word)
-		if temp == "kerfuffle" || temp == "sharbert" || temp == "fornax" {
-			splitChirp[index] = "****"
+	quoteOf := uuid.NullUUID{}
+	if params.QuoteChirpID != nil {
+		quoteOf, err = cfg.resolveQuote(r.Context(), JwtUser, *params.QuoteChirpID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find quoted chirp", err)
+			return
 		}
 	}
 
-	cleanedChirp := strings.Join(splitChirp, " ")
-
-
-	chirp, err := cfg.db.NewChirp(r.Context(), database.NewChirpParams{
+	chirp, err := cfg.createChirp(r.Context(), database.NewChirpParams{
 		Body: cleanedChirp,
 		UserID: JwtUser,
-	})
+		QuoteOf: quoteOf,
+		IsQuote: quoteOf.Valid,
+		PublishAt: publishAt,
+	}, params.AttachmentIDs, verdict)
+	if errors.Is(err, errUnknownAttachment) {
+		respondWithError(w, http.StatusBadRequest, "Unknown or already used attachment", err)
+		return
+	}
     if err != nil {
         respondWithError(w, http.StatusInternalServerError, "Can not post chirp!", err)  // Fix: proper error handling
         return
     }
+
+	cfg.recordSpamCheck(r.Context(), JwtUser, uuid.NullUUID{UUID: chirp.ID, Valid: true}, spamResult)
+
+	if chirp.Status == chirpStatusPublished {
+		cfg.afterChirpCreated(r.Context(), chirp)
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), JwtUser, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Can not post chirp!", err)
+		return
+	}
     
-    respondWithJSON(w, http.StatusCreated, Chirp{  // Fix: use http.StatusCreated (201)
-        ID:        chirp.ID,
-        CreatedAt: chirp.CreatedAt,
-        UpdatedAt: chirp.UpdatedAt,
-        Body:      chirp.Body,
-		UserID:    JwtUser,
-    })
+    respondWithJSON(w, http.StatusCreated, chirps[0])  // Fix: use http.StatusCreated (201)
+}
+
+// vetChirp runs every check a chirp must pass before it is posted: the
+// author's posting throttle, their plan's length and daily limits,
+// moderation filters, blocked mentions and spam scoring. It writes the
+// error response itself and returns false if the chirp can't be posted.
+// Rejected and throttled spam checks are recorded here; the caller records
+// the rest once the chirp exists.
+func (cfg *apiConfig) vetChirp(w http.ResponseWriter, r *http.Request, author database.User, limits entitlements.Entitlements, body string, isQuote bool) (moderation.Result, spamCheck, bool) {
+	if author.PostingThrottledUntil.Valid && author.PostingThrottledUntil.Time.After(time.Now().UTC()) {
+		respondThrottled(w, author.PostingThrottledUntil.Time)
+		return moderation.Result{}, spamCheck{}, false
+	}
+
+	if err := limits.CheckChirpLength(body); err != nil {
+		respondLimitExceeded(w, err)
+		return moderation.Result{}, spamCheck{}, false
+	}
+	posted, err := cfg.chirpsToday(r.Context(), author.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Can not post chirp!", err)
+		return moderation.Result{}, spamCheck{}, false
+	}
+	if err := limits.CheckChirpsPerDay(posted); err != nil {
+		respondLimitExceeded(w, err)
+		return moderation.Result{}, spamCheck{}, false
+	}
+	if isQuote && strings.TrimSpace(body) == "" {
+		respondWithError(w, http.StatusBadRequest, "Quote-chirps need a body", nil)
+		return moderation.Result{}, spamCheck{}, false
+	}
+
+	verdict := cfg.moderation.Pipeline().Check(body)
+	if verdict.Action == moderation.ActionReject {
+		respondWithError(w, http.StatusBadRequest, "Chirp rejected by moderation: "+verdict.Reasons(), nil)
+		return moderation.Result{}, spamCheck{}, false
+	}
+
+	blockers, err := cfg.blockedMentions(r.Context(), author.ID, body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Can not post chirp!", err)
+		return moderation.Result{}, spamCheck{}, false
+	}
+	if len(blockers) > 0 {
+		respondWithError(w, http.StatusForbidden, "You can't mention @"+strings.Join(blockers, ", @"), nil)
+		return moderation.Result{}, spamCheck{}, false
+	}
+
+	spamResult, err := cfg.checkSpam(r.Context(), author, body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Can not post chirp!", err)
+		return moderation.Result{}, spamCheck{}, false
+	}
+	switch spamResult.result.Action {
+	case spam.ActionThrottle:
+		cfg.recordSpamCheck(r.Context(), author.ID, uuid.NullUUID{}, spamResult)
+		respondThrottled(w, cfg.throttlePosting(r.Context(), author.ID))
+		return moderation.Result{}, spamCheck{}, false
+	case spam.ActionReject:
+		cfg.recordSpamCheck(r.Context(), author.ID, uuid.NullUUID{}, spamResult)
+		respondWithError(w, http.StatusBadRequest, "Chirp rejected as spam: "+strings.Join(spamResult.result.Reasons, "; "), nil)
+		return moderation.Result{}, spamCheck{}, false
+	case spam.ActionHold:
+		verdict = holdForSpam(verdict, spamResult)
+	}
+
+	return verdict, spamResult, true
+}
+
+// resolveQuote checks that userID may quote chirpID and returns the chirp
+// the quote should point at. It returns sql.ErrNoRows if the chirp isn't
+// published or either user has blocked the other.
+func (cfg *apiConfig) resolveQuote(ctx context.Context, userID, chirpID uuid.UUID) (uuid.NullUUID, error) {
+	quoted, err := cfg.db.GetChirpsByID(ctx, chirpID)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	if quoted.Status != chirpStatusPublished {
+		return uuid.NullUUID{}, sql.ErrNoRows
+	}
+	blocked, err := cfg.blockedEitherWay(ctx, userID, quoted.UserID)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	if blocked {
+		return uuid.NullUUID{}, sql.ErrNoRows
+	}
+	return uuid.NullUUID{UUID: originalChirpID(quoted), Valid: true}, nil
+}
+
+// chirpStatusFor decides the status a new chirp is stored with. A held
+// verdict wins over a publish time, so the chirp is reviewed first.
+func chirpStatusFor(publishAt sql.NullTime, verdict moderation.Result) string {
+	if verdict.Action == moderation.ActionHold {
+		return chirpStatusHeld
+	}
+	if publishAt.Valid {
+		return chirpStatusScheduled
+	}
+	return chirpStatusPublished
+}
+
+// createChirp inserts a chirp together with its hashtags, mentions and
+// attachments in one transaction. Chirps the moderation pipeline held are
+// stored with the held status and queued for review; chirps with a publish
+// time wait for the scheduler.
+func (cfg *apiConfig) createChirp(ctx context.Context, params database.NewChirpParams, attachmentIDs []uuid.UUID, verdict moderation.Result) (database.Chirp, error) {
+	params.Status = chirpStatusFor(params.PublishAt, verdict)
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	chirp, err := q.NewChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if err := saveChirpEntities(ctx, q, chirp); err != nil {
+		return database.Chirp{}, err
+	}
+	if err := attachToChirp(ctx, q, chirp, attachmentIDs); err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.Status == chirpStatusHeld {
+		err := q.HoldChirp(ctx, database.HoldChirpParams{ChirpID: chirp.ID, Reasons: verdict.Reasons()})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	return chirp, tx.Commit()
 }
@@ -0,0 +1,74 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(ctx, "attachments/abc", "image/png", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "attachments/abc", "image/png", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := store.Open(ctx, "attachments/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "second" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+
+	entries, err := os.ReadDir(store.root + "/attachments")
+	if err != nil || len(entries) != 1 {
+		t.Errorf("expected no temporary files left behind, got %v", entries)
+	}
+
+	if err := store.Delete(ctx, "attachments/abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(ctx, "attachments/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "attachments/abc"); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed, got %v", err)
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	for _, key := range []string{"a", "attachments/0f8fad5b-d9cb.jpg", "x/y_z/1"} {
+		if !ValidKey(key) {
+			t.Errorf("expected %q to be valid", key)
+		}
+	}
+	for _, key := range []string{"", "/etc/passwd", "../secret", "a/../../b", "a//b", "a/", `a\b`, "a b", "./a"} {
+		if ValidKey(key) {
+			t.Errorf("expected %q to be invalid", key)
+		}
+	}
+}
+
+func TestLocalRejectsInvalidKeys(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(context.Background(), "../escape", "text/plain", strings.NewReader("x"))
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+}
@@ -14,7 +14,7 @@ import (
 )
 
 const getUserFromRToken = `-- name: GetUserFromRToken :one
-SELECT id, users.created_at, users.updated_at, email, hashed_password, is_chirpy_red, username, token, refresh_tokens.created_at, refresh_tokens.updated_at, user_id, expires_at, revoked_at FROM users
+SELECT id, users.created_at, users.updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, account_state, posting_throttled_until, token, refresh_tokens.created_at, refresh_tokens.updated_at, user_id, expires_at, revoked_at FROM users
 INNER JOIN refresh_tokens
 ON users.id = refresh_tokens.user_id
 WHERE refresh_tokens.token = $1
@@ -23,19 +23,23 @@ AND refresh_tokens.revoked_at IS NULL
 `
 
 type GetUserFromRTokenRow struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword sql.NullString
-	IsChirpyRed    sql.NullBool
-	Username       string
-	Token          string
-	CreatedAt_2    time.Time
-	UpdatedAt_2    time.Time
-	UserID         uuid.UUID
-	ExpiresAt      time.Time
-	RevokedAt      sql.NullTime
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Email                 string
+	HashedPassword        sql.NullString
+	IsChirpyRed           sql.NullBool
+	Username              string
+	Role                  string
+	SuspendedUntil        sql.NullTime
+	AccountState          string
+	PostingThrottledUntil sql.NullTime
+	Token                 string
+	CreatedAt_2           time.Time
+	UpdatedAt_2           time.Time
+	UserID                uuid.UUID
+	ExpiresAt             time.Time
+	RevokedAt             sql.NullTime
 }
 
 func (q *Queries) GetUserFromRToken(ctx context.Context, token string) (GetUserFromRTokenRow, error) {
@@ -49,6 +53,10 @@ func (q *Queries) GetUserFromRToken(ctx context.Context, token string) (GetUserF
 		&i.HashedPassword,
 		&i.IsChirpyRed,
 		&i.Username,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountState,
+		&i.PostingThrottledUntil,
 		&i.Token,
 		&i.CreatedAt_2,
 		&i.UpdatedAt_2,
@@ -10,7 +10,7 @@ import (
 )
 
 const searchUser = `-- name: SearchUser :many
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, account_state, posting_throttled_until FROM users
 `
 
 func (q *Queries) SearchUser(ctx context.Context) ([]User, error) {
@@ -30,6 +30,10 @@ func (q *Queries) SearchUser(ctx context.Context) ([]User, error) {
 			&i.HashedPassword,
 			&i.IsChirpyRed,
 			&i.Username,
+			&i.Role,
+			&i.SuspendedUntil,
+			&i.AccountState,
+			&i.PostingThrottledUntil,
 		); err != nil {
 			return nil, err
 		}
@@ -7,29 +7,39 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const newChirp = `-- name: NewChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, is_quote, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, rechirp_of, quote_of, is_quote, status, publish_at
 `
 
 type NewChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	QuoteOf   uuid.NullUUID
+	IsQuote   bool
+	Status    string
+	PublishAt sql.NullTime
 }
 
 func (q *Queries) NewChirp(ctx context.Context, arg NewChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, newChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, newChirp, arg.Body, arg.UserID, arg.QuoteOf, arg.IsQuote, arg.Status, arg.PublishAt)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +47,31 @@ func (q *Queries) NewChirp(ctx context.Context, arg NewChirpParams) (Chirp, erro
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.IsQuote,
+		&i.Status,
+		&i.PublishAt,
 	)
 	return i, err
 }
+
+const countPostedChirpsSince = `-- name: CountPostedChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+AND created_at >= $2
+AND status <> 'draft'
+AND rechirp_of IS NULL
+`
+
+type CountPostedChirpsSinceParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+func (q *Queries) CountPostedChirpsSince(ctx context.Context, arg CountPostedChirpsSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostedChirpsSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
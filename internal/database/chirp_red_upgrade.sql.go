@@ -7,17 +7,26 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
-const upgradeUser = `-- name: UpgradeUser :exec
+const setChirpyRed = `-- name: SetChirpyRed :execrows
 UPDATE users
-SET is_chirpy_red = TRUE
+SET is_chirpy_red = $2, updated_at = NOW()
 WHERE id = $1
 `
 
-func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUser, id)
-	return err
+type SetChirpyRedParams struct {
+	ID          uuid.UUID
+	IsChirpyRed sql.NullBool
+}
+
+func (q *Queries) SetChirpyRed(ctx context.Context, arg SetChirpyRedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setChirpyRed, arg.ID, arg.IsChirpyRed)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
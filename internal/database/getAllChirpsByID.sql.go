@@ -12,7 +12,7 @@ import (
 )
 
 const getAllChirpsByUserID = `-- name: GetAllChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, rechirp_of, quote_of, is_quote, status, publish_at FROM chirps
 WHERE user_id = $1
 ORDER BY created_at
 `
@@ -32,6 +32,11 @@ func (q *Queries) GetAllChirpsByUserID(ctx context.Context, userID uuid.UUID) ([
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.IsQuote,
+			&i.Status,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
@@ -10,7 +10,7 @@ import (
 )
 
 const getAllUsers = `-- name: GetAllUsers :many
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, account_state, posting_throttled_until FROM users
 ORDER BY email
 `
 
@@ -31,6 +31,10 @@ func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
 			&i.HashedPassword,
 			&i.IsChirpyRed,
 			&i.Username,
+			&i.Role,
+			&i.SuspendedUntil,
+			&i.AccountState,
+			&i.PostingThrottledUntil,
 		); err != nil {
 			return nil, err
 		}
@@ -12,7 +12,7 @@ import (
 )
 
 const getChirpsByID = `-- name: GetChirpsByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, rechirp_of, quote_of, is_quote, status, publish_at FROM chirps
 WHERE id = $1
 `
 
@@ -25,6 +25,11 @@ func (q *Queries) GetChirpsByID(ctx context.Context, id uuid.UUID) (Chirp, error
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.IsQuote,
+		&i.Status,
+		&i.PublishAt,
 	)
 	return i, err
 }
@@ -7,15 +7,19 @@ package database
 
 import (
 	"context"
+
+	"github.com/google/uuid"
 )
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, rechirp_of, quote_of, is_quote, status, publish_at FROM chirps
+WHERE status = 'published'
+OR (status = 'hidden' AND user_id = $1)
 ORDER BY created_at
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -29,6 +33,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.IsQuote,
+			&i.Status,
+			&i.PublishAt,
 		); err != nil {
 			return nil, err
 		}
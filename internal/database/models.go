@@ -6,17 +6,251 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AccountStateChange struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	ChangedBy      uuid.UUID
+	FromState      string
+	ToState        string
+	SuspendedUntil sql.NullTime
+	Reason         string
+	CreatedAt      time.Time
+}
+
+type Attachment struct {
+	ID                   uuid.UUID
+	UserID               uuid.UUID
+	ChirpID              uuid.NullUUID
+	Position             int32
+	ContentType          string
+	SizeBytes            int64
+	Width                int32
+	Height               int32
+	BlobKey              string
+	ThumbnailKey         string
+	ThumbnailContentType string
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID    uuid.UUID
+	Tag        string
+	StartIndex int32
+	EndIndex   int32
+	CreatedAt  time.Time
+}
+
+type ChirpLink struct {
+	ChirpID  uuid.UUID
+	Url      string
+	Position int32
+}
+
+type ChirpMention struct {
+	ChirpID    uuid.UUID
+	UserID     uuid.UUID
+	StartIndex int32
+	EndIndex   int32
+	CreatedAt  time.Time
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	RechirpOf uuid.NullUUID
+	QuoteOf   uuid.NullUUID
+	IsQuote   bool
+	Status    string
+	PublishAt sql.NullTime
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	LastReadAt     sql.NullTime
+	JoinedAt       time.Time
+}
+
+type Conversation struct {
+	ID            uuid.UUID
+	CreatedBy     uuid.UUID
+	DirectKey     sql.NullString
+	CreatedAt     time.Time
+	LastMessageAt time.Time
+}
+
+type FollowerCount struct {
+	UserID    uuid.UUID
+	Followers int64
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type HeldChirp struct {
+	ChirpID   uuid.UUID
+	Reasons   string
+	CreatedAt time.Time
+}
+
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	DedupeKey   sql.NullString
+	RunAt       time.Time
+	LockedAt    sql.NullTime
+	LastError   sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type LinkPreview struct {
+	Url         string
+	Status      string
+	Title       string
+	Description string
+	ImageUrl    string
+	SiteName    string
+	FetchedAt   time.Time
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type List struct {
+	ID          uuid.UUID
+	OwnerID     uuid.UUID
+	Name        string
+	Description string
+	IsPrivate   bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type MessageSetting struct {
+	UserID    uuid.UUID
+	AllowFrom string
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type ModerationDecision struct {
+	ID            uuid.UUID
+	ReportID      uuid.UUID
+	ModeratorID   uuid.UUID
+	SubjectUserID uuid.UUID
+	ChirpID       uuid.NullUUID
+	Action        string
+	Note          string
+	CreatedAt     time.Time
+}
+
+type ModerationFilter struct {
+	ID        uuid.UUID
+	Kind      string
+	Name      string
+	Action    string
+	Pattern   string
+	Position  int32
+	Enabled   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ModerationTerm struct {
+	FilterID  uuid.UUID
+	Term      string
+	CreatedAt time.Time
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+type NotificationPreference struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	GroupKey  string
+	ReadAt    sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type PolkaEvent struct {
+	EventID     string
+	Event       string
+	ProcessedAt time.Time
+}
+
+type PolkaWebhookLog struct {
+	ID         uuid.UUID
+	EventID    sql.NullString
+	Event      string
+	UserID     uuid.NullUUID
+	Body       string
+	Status     string
+	Error      sql.NullString
+	ReceivedAt time.Time
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,12 +262,111 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
-type User struct {
+type Report struct {
 	ID             uuid.UUID
+	ReporterID     uuid.UUID
+	ReportedUserID uuid.UUID
+	ChirpID        uuid.NullUUID
+	Reason         string
+	Details        string
+	Status         string
+	ClaimedBy      uuid.NullUUID
+	ClaimedAt      sql.NullTime
+	ResolvedAt     sql.NullTime
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Email          string
-	HashedPassword sql.NullString
-	IsChirpyRed    sql.NullBool
-	Username       string
+}
+
+type SpamCheck struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	ChirpID   uuid.NullUUID
+	Simhash   sql.NullInt64
+	Score     float64
+	Action    string
+	Reasons   string
+	Signals   json.RawMessage
+	CreatedAt time.Time
+}
+
+type Subscription struct {
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	CanceledAt       sql.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type SuppressedTag struct {
+	Tag          string
+	SuppressedBy uuid.NullUUID
+	Reason       string
+	CreatedAt    time.Time
+}
+
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type TrendingTag struct {
+	TimeWindow   string
+	Rank         int32
+	Tag          string
+	Score        float64
+	RecentCount  int64
+	BaselineRate float64
+	ComputedAt   time.Time
+}
+
+type User struct {
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Email                 string
+	HashedPassword        sql.NullString
+	IsChirpyRed           sql.NullBool
+	Username              string
+	Role                  string
+	SuspendedUntil        sql.NullTime
+	AccountState          string
+	PostingThrottledUntil sql.NullTime
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	WebhookID     uuid.UUID
+	EventID       uuid.UUID
+	Event         string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt sql.NullTime
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type WebhookDeliveryAttempt struct {
+	DeliveryID   uuid.UUID
+	AttemptedAt  time.Time
+	StatusCode   sql.NullInt32
+	Error        sql.NullString
+	ResponseBody string
+	DurationMs   int32
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Url       string
+	Secret    string
+	Events    []string
+	Global    bool
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
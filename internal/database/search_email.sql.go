@@ -11,7 +11,7 @@ import (
 
 const searchEmail = `-- name: SearchEmail :one
 
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, account_state, posting_throttled_until 
               FROM users 
               WHERE email = $1
 `
@@ -27,6 +27,10 @@ func (q *Queries) SearchEmail(ctx context.Context, email string) (User, error) {
 		&i.HashedPassword,
 		&i.IsChirpyRed,
 		&i.Username,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountState,
+		&i.PostingThrottledUntil,
 	)
 	return i, err
 }
@@ -21,7 +21,7 @@ VALUES (
     FALSE,
     $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, role, suspended_until, account_state, posting_throttled_until
 `
 
 type CreateUserParams struct {
@@ -41,6 +41,10 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.HashedPassword,
 		&i.IsChirpyRed,
 		&i.Username,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.AccountState,
+		&i.PostingThrottledUntil,
 	)
 	return i, err
 }
@@ -0,0 +1,87 @@
+// Package entities finds #hashtags and @mentions in chirp bodies.
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Kind string
+
+const (
+	Hashtag Kind = "hashtag"
+	Mention Kind = "mention"
+)
+
+// Entity is one hashtag or mention. Start and End are offsets in Unicode
+// code points into the body, End exclusive, and cover the leading # or @.
+// Text is the entity without its sigil.
+type Entity struct {
+	Kind  Kind
+	Text  string
+	Start int
+	End   int
+}
+
+// Parse returns the entities in body in the order they appear.
+//
+// A sigil only starts an entity at the beginning of the body or after a
+// character that can't be part of a word, so "me@example.com" and "C#"
+// aren't matched. Hashtags may contain letters, digits and underscores but
+// must contain at least one letter; mentions may contain ASCII letters,
+// digits and underscores.
+func Parse(body string) []Entity {
+	runes := []rune(body)
+	found := []Entity{}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != '#' && r != '@' {
+			continue
+		}
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+
+		kind, valid := Hashtag, isHashtagRune
+		if r == '@' {
+			kind, valid = Mention, isMentionRune
+		}
+
+		end := i + 1
+		for end < len(runes) && valid(runes[end]) {
+			end++
+		}
+		text := string(runes[i+1 : end])
+		if text == "" || (kind == Hashtag && !strings.ContainsFunc(text, unicode.IsLetter)) {
+			continue
+		}
+
+		found = append(found, Entity{Kind: kind, Text: text, Start: i, End: end})
+		i = end - 1
+	}
+	return found
+}
+
+// NormalizeTag is the form hashtags are stored and looked up in.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// NormalizeUsername is the form mentions are looked up in. Usernames are
+// matched case-insensitively, so @Alice mentions alice; the database keeps
+// them unique regardless of case.
+func NormalizeUsername(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "@"))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '#' || r == '@' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isHashtagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isMentionRune(r rune) bool {
+	return r == '_' || (r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)))
+}
@@ -0,0 +1,50 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		body string
+		want []Entity
+	}{
+		{
+			body: "hello #Go and @bob_1!",
+			want: []Entity{
+				{Kind: Hashtag, Text: "Go", Start: 6, End: 9},
+				{Kind: Mention, Text: "bob_1", Start: 14, End: 20},
+			},
+		},
+		{
+			// Offsets count code points, not bytes.
+			body: "café #naïve",
+			want: []Entity{{Kind: Hashtag, Text: "naïve", Start: 5, End: 11}},
+		},
+		{body: "mail me@example.com", want: []Entity{}},
+		{body: "C# and #2024 and # alone", want: []Entity{}},
+		{body: "##double", want: []Entity{}},
+	}
+
+	for _, c := range cases {
+		got := Parse(c.body)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", c.body, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	if got := NormalizeTag("#GoLang"); got != "golang" {
+		t.Errorf("NormalizeTag = %q, want %q", got, "golang")
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	for _, name := range []string{"@Alice", "alice", "ALICE"} {
+		if got := NormalizeUsername(name); got != "alice" {
+			t.Errorf("NormalizeUsername(%q) = %q, want %q", name, got, "alice")
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package entitlements decides what a user's plan lets them do. Features
+// ask for a user's Entitlements rather than checking whether they pay, so
+// plans can change without touching every feature.
+package entitlements
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	PlanFree = "free"
+	PlanRed  = "red"
+
+	StatusActive  = "active"
+	StatusPastDue = "past_due"
+	// StatusCanceled subscriptions won't renew but keep their plan until
+	// the paid period ends.
+	StatusCanceled = "canceled"
+	StatusExpired  = "expired"
+
+	// Period is how long one payment covers when the billing provider
+	// doesn't say.
+	Period = 30 * 24 * time.Hour
+	// GracePeriod is how long a past-due subscription keeps its plan after
+	// the period ends, while the payment is retried.
+	GracePeriod = 3 * 24 * time.Hour
+)
+
+// Names of the limits, as reported to clients.
+const (
+	LimitChirpLength  = "max_chirp_length"
+	LimitChirpsPerDay = "max_chirps_per_day"
+	LimitAttachments  = "max_attachments"
+)
+
+// Entitlements are the limits of one plan. A zero MaxChirpsPerDay means
+// there's no daily cap.
+type Entitlements struct {
+	Plan            string
+	MaxChirpLength  int
+	MaxChirpsPerDay int
+	MaxAttachments  int
+}
+
+// LimitError reports which limit a request went over.
+type LimitError struct {
+	Limit string
+	Max   int
+	Plan  string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s is %d on the %s plan", e.Limit, e.Max, e.Plan)
+}
+
+func (e Entitlements) exceeded(limit string, max int) error {
+	return &LimitError{Limit: limit, Max: max, Plan: e.Plan}
+}
+
+// CheckChirpLength checks the length of a chirp body in characters, so
+// non-ASCII text gets the same allowance as ASCII.
+func (e Entitlements) CheckChirpLength(body string) error {
+	if utf8.RuneCountInString(body) > e.MaxChirpLength {
+		return e.exceeded(LimitChirpLength, e.MaxChirpLength)
+	}
+	return nil
+}
+
+// CheckChirpsPerDay checks whether one more chirp may be posted after
+// posted in the last day.
+func (e Entitlements) CheckChirpsPerDay(posted int) error {
+	if e.MaxChirpsPerDay > 0 && posted >= e.MaxChirpsPerDay {
+		return e.exceeded(LimitChirpsPerDay, e.MaxChirpsPerDay)
+	}
+	return nil
+}
+
+func (e Entitlements) CheckAttachments(n int) error {
+	if n > e.MaxAttachments {
+		return e.exceeded(LimitAttachments, e.MaxAttachments)
+	}
+	return nil
+}
+
+// Policy maps plans to what they grant.
+type Policy struct {
+	Plans map[string]Entitlements
+}
+
+// DefaultPolicy returns the built-in limits, for the caller to adjust.
+func DefaultPolicy() Policy {
+	return Policy{Plans: map[string]Entitlements{
+		PlanFree: {Plan: PlanFree, MaxChirpLength: 140, MaxChirpsPerDay: 100, MaxAttachments: 4},
+		PlanRed:  {Plan: PlanRed, MaxChirpLength: 560, MaxChirpsPerDay: 1000, MaxAttachments: 8},
+	}}
+}
+
+// For returns the entitlements of a plan. Unknown plans get the free ones.
+func (p Policy) For(plan string) Entitlements {
+	if e, ok := p.Plans[plan]; ok {
+		return e
+	}
+	return p.Plans[PlanFree]
+}
+
+type Subscription struct {
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+}
+
+// ActiveAt reports whether s still grants its plan at now.
+func (s Subscription) ActiveAt(now time.Time) bool {
+	switch s.Status {
+	case StatusActive, StatusCanceled:
+		return now.Before(s.CurrentPeriodEnd)
+	case StatusPastDue:
+		return now.Before(s.CurrentPeriodEnd.Add(GracePeriod))
+	}
+	return false
+}
+
+// ExpiresAt is when s stops granting its plan, unless it's renewed.
+func (s Subscription) ExpiresAt() time.Time {
+	if s.Status == StatusPastDue {
+		return s.CurrentPeriodEnd.Add(GracePeriod)
+	}
+	return s.CurrentPeriodEnd
+}
+
+// Resolve returns what a user with subscription s may do at now. The zero
+// Subscription means the user has never subscribed.
+func (p Policy) Resolve(s Subscription, now time.Time) Entitlements {
+	if !s.ActiveAt(now) {
+		return p.For(PlanFree)
+	}
+	return p.For(s.Plan)
+}
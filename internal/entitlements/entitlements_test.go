@@ -0,0 +1,89 @@
+package entitlements
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	policy := DefaultPolicy()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		sub  Subscription
+		want string
+	}{
+		"never subscribed":     {Subscription{}, PlanFree},
+		"active":               {Subscription{PlanRed, StatusActive, now.Add(time.Hour)}, PlanRed},
+		"active but lapsed":    {Subscription{PlanRed, StatusActive, now.Add(-time.Hour)}, PlanFree},
+		"canceled, still paid": {Subscription{PlanRed, StatusCanceled, now.Add(time.Hour)}, PlanRed},
+		"canceled and over":    {Subscription{PlanRed, StatusCanceled, now.Add(-time.Hour)}, PlanFree},
+		"past due, in grace":   {Subscription{PlanRed, StatusPastDue, now.Add(-time.Hour)}, PlanRed},
+		"past due, grace over": {Subscription{PlanRed, StatusPastDue, now.Add(-GracePeriod - time.Hour)}, PlanFree},
+		"expired":              {Subscription{PlanRed, StatusExpired, now.Add(time.Hour)}, PlanFree},
+		"unknown plan":         {Subscription{"platinum", StatusActive, now.Add(time.Hour)}, PlanFree},
+	} {
+		if got := policy.Resolve(tc.sub, now).Plan; got != tc.want {
+			t.Errorf("%s: got plan %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestRedGetsMore(t *testing.T) {
+	policy := DefaultPolicy()
+	free, red := policy.For(PlanFree), policy.For(PlanRed)
+	if red.MaxChirpLength <= free.MaxChirpLength || red.MaxAttachments <= free.MaxAttachments {
+		t.Errorf("expected Red to raise the limits, got %+v and %+v", free, red)
+	}
+}
+
+func TestChecksNameTheLimit(t *testing.T) {
+	free := Entitlements{Plan: PlanFree, MaxChirpLength: 5, MaxChirpsPerDay: 2, MaxAttachments: 1}
+	for want, err := range map[string]error{
+		LimitChirpLength:  free.CheckChirpLength("too long"),
+		LimitChirpsPerDay: free.CheckChirpsPerDay(2),
+		LimitAttachments:  free.CheckAttachments(2),
+	} {
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != want || limitErr.Plan != PlanFree {
+			t.Errorf("expected %s to be exceeded, got %v", want, err)
+		}
+	}
+
+	if err := free.CheckChirpLength("short"); err != nil {
+		t.Errorf("expected a chirp at the limit to pass, got %v", err)
+	}
+	if err := free.CheckChirpLength("héllö"); err != nil {
+		t.Errorf("expected length to count characters, not bytes, got %v", err)
+	}
+	if err := free.CheckChirpsPerDay(1); err != nil {
+		t.Errorf("expected a second chirp to pass, got %v", err)
+	}
+	if err := (Entitlements{}).CheckChirpsPerDay(1000); err != nil {
+		t.Errorf("expected no daily cap, got %v", err)
+	}
+}
+
+func TestPolicyOverrides(t *testing.T) {
+	policy := DefaultPolicy()
+	red := policy.Plans[PlanRed]
+	red.MaxChirpLength = 1000
+	policy.Plans[PlanRed] = red
+
+	if got := policy.For(PlanRed).MaxChirpLength; got != 1000 {
+		t.Errorf("expected the override, got %d", got)
+	}
+	if got := DefaultPolicy().For(PlanRed).MaxChirpLength; got == 1000 {
+		t.Error("expected the default policy to be unchanged")
+	}
+}
+
+func TestExpiresAt(t *testing.T) {
+	end := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got := (Subscription{PlanRed, StatusActive, end}).ExpiresAt(); !got.Equal(end) {
+		t.Errorf("active: got %v", got)
+	}
+	if got := (Subscription{PlanRed, StatusPastDue, end}).ExpiresAt(); !got.Equal(end.Add(GracePeriod)) {
+		t.Errorf("past due: got %v", got)
+	}
+}
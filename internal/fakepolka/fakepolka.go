@@ -0,0 +1,306 @@
+// Package fakepolka stands in for Polka, the payment provider, when
+// developing and testing Chirpy. It builds the webhook events Polka sends,
+// authenticates them the way Polka does, and can replay recorded events,
+// repeat deliveries the way Polka's retries do, and deliver out of order.
+package fakepolka
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/webhooks"
+)
+
+const (
+	// SignatureHeader carries a webhooks.Sign signature made with the
+	// shared webhook secret.
+	SignatureHeader = "Polka-Signature"
+
+	UserUpgraded        = "user.upgraded"
+	UserDowngraded      = "user.downgraded"
+	SubscriptionRenewed = "subscription.renewed"
+	PaymentFailed       = "payment.failed"
+
+	DefaultURL = "http://localhost:8080/api/polka/webhooks"
+
+	// loggedProcessed and loggedUnverified are the webhook log statuses of
+	// requests Chirpy applied.
+	loggedProcessed  = "processed"
+	loggedUnverified = "unverified-idempotency"
+)
+
+// ErrUndelivered means Chirpy never accepted the event, even after retries.
+var ErrUndelivered = errors.New("webhook not delivered")
+
+type Data struct {
+	UserID           string     `json:"user_id"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
+}
+
+type Event struct {
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event"`
+	Data  Data   `json:"data"`
+}
+
+// NewEvent returns an event of the given kind for a user, with a fresh ID.
+func NewEvent(kind string, userID uuid.UUID) Event {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return Event{
+		ID:    "evt_" + hex.EncodeToString(b),
+		Event: kind,
+		Data:  Data{UserID: userID.String()},
+	}
+}
+
+// WithPeriodEnd sets the end of the period an upgrade or renewal pays for.
+func (e Event) WithPeriodEnd(t time.Time) Event {
+	t = t.UTC()
+	e.Data.CurrentPeriodEnd = &t
+	return e
+}
+
+// Body is the JSON Polka posts for e.
+func (e Event) Body() []byte {
+	b, _ := json.Marshal(e)
+	return b
+}
+
+// Client posts webhooks to Chirpy. Requests are signed when Secret is set
+// and carry an "ApiKey" Authorization header when APIKey is set, matching
+// the two ways Chirpy can be configured.
+type Client struct {
+	URL    string
+	APIKey string
+	Secret string
+	HTTP   *http.Client
+	// Now is the signing time. It defaults to time.Now; tests move it to
+	// produce stale signatures.
+	Now func() time.Time
+}
+
+type Response struct {
+	StatusCode int
+	Body       string
+}
+
+// Send makes one delivery attempt of a webhook body.
+func (c *Client) Send(ctx context.Context, body []byte) (Response, error) {
+	url := c.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.APIKey)
+	}
+	if c.Secret != "" {
+		now := time.Now()
+		if c.Now != nil {
+			now = c.Now()
+		}
+		req.Header.Set(SignatureHeader, webhooks.Sign(c.Secret, now, body))
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	return Response{StatusCode: resp.StatusCode, Body: string(respBody)}, nil
+}
+
+// RetryPolicy is how Polka retries a delivery that failed. Like Polka, it
+// retries network errors and 5xx and 429 responses but gives up on other
+// client errors.
+type RetryPolicy struct {
+	Attempts int
+	Delay    time.Duration
+}
+
+func retryable(resp Response, err error) bool {
+	return err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+}
+
+// Deliver sends body, retrying according to p. It returns every response
+// received, and ErrUndelivered unless the last one was a 2xx.
+func (c *Client) Deliver(ctx context.Context, body []byte, p RetryPolicy) ([]Response, error) {
+	attempts := max(p.Attempts, 1)
+	var responses []Response
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return responses, ctx.Err()
+			case <-time.After(p.Delay):
+			}
+		}
+		resp, err := c.Send(ctx, body)
+		if err == nil {
+			responses = append(responses, resp)
+		}
+		if !retryable(resp, err) {
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return responses, fmt.Errorf("%w: status %d", ErrUndelivered, resp.StatusCode)
+			}
+			return responses, nil
+		}
+		if i == attempts-1 {
+			if err != nil {
+				return responses, fmt.Errorf("%w: %v", ErrUndelivered, err)
+			}
+			return responses, fmt.Errorf("%w: status %d", ErrUndelivered, resp.StatusCode)
+		}
+	}
+	return responses, nil
+}
+
+// Options shape a Replay.
+type Options struct {
+	// Duplicates sends every event this many extra times, as Polka does
+	// when it doesn't see a response in time.
+	Duplicates int
+	// Shuffle delivers the events, duplicates included, in a random order
+	// picked by Seed.
+	Shuffle bool
+	Seed    uint64
+	Retry   RetryPolicy
+}
+
+// Result is the outcome of delivering one body during a Replay.
+type Result struct {
+	Body      []byte
+	Responses []Response
+	Err       error
+}
+
+// Plan returns the order Replay would deliver bodies in.
+func Plan(bodies [][]byte, opts Options) [][]byte {
+	var planned [][]byte
+	for _, body := range bodies {
+		for i := 0; i <= opts.Duplicates; i++ {
+			planned = append(planned, body)
+		}
+	}
+	if opts.Shuffle {
+		r := mathrand.New(mathrand.NewPCG(opts.Seed, opts.Seed))
+		r.Shuffle(len(planned), func(i, j int) {
+			planned[i], planned[j] = planned[j], planned[i]
+		})
+	}
+	return planned
+}
+
+// Replay delivers bodies one at a time. A failed delivery doesn't stop the
+// rest; it stops only if ctx is done.
+func (c *Client) Replay(ctx context.Context, bodies [][]byte, opts Options) []Result {
+	var results []Result
+	for _, body := range Plan(bodies, opts) {
+		if ctx.Err() != nil {
+			break
+		}
+		responses, err := c.Deliver(ctx, body, opts.Retry)
+		results = append(results, Result{Body: body, Responses: responses, Err: err})
+	}
+	return results
+}
+
+// logEntry is the part of a GET /admin/polka/webhooks entry a replay needs.
+type logEntry struct {
+	Body       string    `json:"body"`
+	Status     string    `json:"status"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// ReadRecording reads webhook bodies to replay, oldest first. It accepts
+// either one JSON body per line, or the JSON array returned by GET
+// /admin/polka/webhooks. That log lists the newest request first and
+// records every request Chirpy received, so only the entries it applied are
+// kept, in the order they were received.
+func ReadRecording(r io.Reader) ([][]byte, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if first == '[' {
+		var entries []logEntry
+		if err := json.NewDecoder(br).Decode(&entries); err != nil {
+			return nil, err
+		}
+		// Reversing first keeps the log's order for entries received at the
+		// same instant, or without a time at all.
+		slices.Reverse(entries)
+		slices.SortStableFunc(entries, func(a, b logEntry) int {
+			return a.ReceivedAt.Compare(b.ReceivedAt)
+		})
+		bodies := make([][]byte, 0, len(entries))
+		for i, e := range entries {
+			// Rejected, ignored, duplicate and failed requests didn't change
+			// anything when they were received.
+			if e.Status != "" && e.Status != loggedProcessed && e.Status != loggedUnverified {
+				continue
+			}
+			if !json.Valid([]byte(e.Body)) {
+				return nil, fmt.Errorf("entry %d: body isn't JSON", i+1)
+			}
+			bodies = append(bodies, []byte(e.Body))
+		}
+		return bodies, nil
+	}
+
+	var bodies [][]byte
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		body := bytes.TrimSpace(scanner.Bytes())
+		if len(body) == 0 {
+			continue
+		}
+		if !json.Valid(body) {
+			return nil, fmt.Errorf("line %d: not JSON", line)
+		}
+		bodies = append(bodies, bytes.Clone(body))
+	}
+	return bodies, scanner.Err()
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
@@ -0,0 +1,141 @@
+package fakepolka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/webhooks"
+)
+
+func TestSendAuthenticates(t *testing.T) {
+	var got struct {
+		auth, sig string
+		body      []byte
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.auth = r.Header.Get("Authorization")
+		got.sig = r.Header.Get(SignatureHeader)
+		got.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, APIKey: "key", Secret: "whsec_test"}
+	event := NewEvent(UserUpgraded, uuid.New()).WithPeriodEnd(time.Now().Add(time.Hour))
+	resp, err := c.Send(context.Background(), event.Body())
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected response %+v %v", resp, err)
+	}
+	if got.auth != "ApiKey key" {
+		t.Errorf("unexpected Authorization %q", got.auth)
+	}
+	if err := webhooks.Verify("whsec_test", got.sig, got.body, time.Now(), webhooks.DefaultTolerance); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+
+	var decoded Event
+	if err := json.Unmarshal(got.body, &decoded); err != nil || decoded.ID != event.ID || decoded.Data.CurrentPeriodEnd == nil {
+		t.Errorf("unexpected body %s", got.body)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	var mu sync.Mutex
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent}
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.WriteHeader(statuses[calls])
+		calls++
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	responses, err := c.Deliver(context.Background(), []byte(`{}`), RetryPolicy{Attempts: 5})
+	if err != nil || len(responses) != 3 {
+		t.Errorf("expected success on the third attempt, got %d responses, %v", len(responses), err)
+	}
+}
+
+func TestDeliverGivesUpOnClientErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	_, err := c.Deliver(context.Background(), []byte(`{}`), RetryPolicy{Attempts: 5})
+	if !errors.Is(err, ErrUndelivered) || calls != 1 {
+		t.Errorf("expected one attempt and ErrUndelivered, got %d attempts, %v", calls, err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	bodies := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	planned := Plan(bodies, Options{Duplicates: 1})
+	if len(planned) != 6 || string(planned[0]) != "a" || string(planned[1]) != "a" {
+		t.Errorf("expected each body twice in order, got %q", planned)
+	}
+
+	shuffled := Plan(bodies, Options{Duplicates: 1, Shuffle: true, Seed: 7})
+	again := Plan(bodies, Options{Duplicates: 1, Shuffle: true, Seed: 7})
+	counts := map[string]int{}
+	for i := range shuffled {
+		counts[string(shuffled[i])]++
+		if string(shuffled[i]) != string(again[i]) {
+			t.Error("expected the same seed to give the same order")
+		}
+	}
+	if counts["a"] != 2 || counts["b"] != 2 || counts["c"] != 2 {
+		t.Errorf("expected every body twice, got %v", counts)
+	}
+}
+
+func TestReadRecording(t *testing.T) {
+	lines := "{\"event\":\"user.upgraded\"}\n\n{\"event\":\"user.downgraded\"}\n"
+	bodies, err := ReadRecording(strings.NewReader(lines))
+	if err != nil || len(bodies) != 2 || string(bodies[1]) != `{"event":"user.downgraded"}` {
+		t.Errorf("unexpected JSON lines result %q %v", bodies, err)
+	}
+
+	// Shaped like GET /admin/polka/webhooks: newest first, with requests
+	// that were never applied mixed in.
+	log := `[
+		{"id":"5","body":"{\"event\":\"user.downgraded\"}","status":"processed","received_at":"2026-03-01T10:00:04Z"},
+		{"id":"4","body":"{\"event\":\"subscription.renewed\"}","status":"duplicate","received_at":"2026-03-01T10:00:03Z"},
+		{"id":"3","body":"{\"event\":\"subscription.renewed\"}","status":"processed","received_at":"2026-03-01T10:00:02Z"},
+		{"id":"2","body":"{\"event\":\"user.upgraded\"}","status":"rejected","error":"bad signature","received_at":"2026-03-01T10:00:01Z"},
+		{"id":"1","body":"{\"event\":\"user.upgraded\"}","status":"processed","received_at":"2026-03-01T10:00:00Z"},
+		{"id":"0","body":"{\"event\":\"chirp.created\"}","status":"ignored","received_at":"2026-03-01T09:59:59Z"}
+	]`
+	bodies, err = ReadRecording(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"event":"user.upgraded"}`, `{"event":"subscription.renewed"}`, `{"event":"user.downgraded"}`}
+	if len(bodies) != len(want) {
+		t.Fatalf("expected %d processed bodies, got %q", len(want), bodies)
+	}
+	for i := range want {
+		if string(bodies[i]) != want[i] {
+			t.Errorf("body %d = %s, want %s", i, bodies[i], want[i])
+		}
+	}
+
+	if _, err := ReadRecording(strings.NewReader("not json\n")); err == nil {
+		t.Error("expected a bad line to fail")
+	}
+}
@@ -0,0 +1,230 @@
+// Package jobs is a small Postgres-backed job queue. Jobs are rows in the
+// jobs table; workers claim them with SELECT ... FOR UPDATE SKIP LOCKED so
+// any number of workers, in any number of server processes, can share one
+// queue without handing the same job out twice.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+const (
+	defaultMaxAttempts  = 5
+	defaultPollInterval = time.Second
+	// staleAfter is how long a job may stay "running" before another worker
+	// assumes its owner died and claims it again.
+	staleAfter = 5 * time.Minute
+	maxBackoff = 10 * time.Minute
+)
+
+// Handler processes one job. Returning an error schedules a retry until the
+// job runs out of attempts.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Options tune a single enqueue.
+type Options struct {
+	// DedupeKey, when set, makes the enqueue a no-op while another pending
+	// or running job has the same key.
+	DedupeKey string
+	// RunAt delays the job. The zero value means "now".
+	RunAt       time.Time
+	MaxAttempts int32
+}
+
+type Queue struct {
+	db *database.Queries
+}
+
+func NewQueue(db *database.Queries) *Queue {
+	return &Queue{db: db}
+}
+
+// WithTx returns a queue that enqueues inside tx, so the jobs only exist if
+// the work that prompted them commits.
+func (q *Queue) WithTx(tx *sql.Tx) *Queue {
+	return &Queue{db: q.db.WithTx(tx)}
+}
+
+// Enqueue adds a job of the given kind. The payload is stored as JSON.
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any, opts Options) error {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now().UTC()
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	return q.db.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        kind,
+		Payload:     dat,
+		MaxAttempts: opts.MaxAttempts,
+		DedupeKey:   sql.NullString{String: opts.DedupeKey, Valid: opts.DedupeKey != ""},
+		RunAt:       opts.RunAt,
+	})
+}
+
+// Every enqueues a job of the given kind immediately and then once per
+// interval until ctx is cancelled. The kind doubles as the dedupe key, so
+// several server processes running the same schedule still produce at most
+// one outstanding job.
+func (q *Queue) Every(ctx context.Context, kind string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := q.Enqueue(ctx, kind, struct{}{}, Options{DedupeKey: kind}); err != nil && ctx.Err() == nil {
+			log.Printf("jobs: couldn't schedule %s: %v", kind, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PruneFailed deletes jobs that failed permanently before the given time.
+// Failed jobs are kept for a while so their last_error can be inspected.
+func (q *Queue) PruneFailed(ctx context.Context, before time.Time) (int64, error) {
+	return q.db.PruneFailedJobs(ctx, before)
+}
+
+// Pool runs a fixed number of workers that pull jobs from a Queue.
+type Pool struct {
+	queue        *Queue
+	workers      int
+	pollInterval time.Duration
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+func NewPool(queue *Queue, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		queue:        queue,
+		workers:      workers,
+		pollInterval: defaultPollInterval,
+		handlers:     map[string]Handler{},
+	}
+}
+
+// Handle registers the handler for a job kind. Call it before Run.
+func (p *Pool) Handle(kind string, h Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[kind] = h
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every
+// in-flight job has returned.
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) work(ctx context.Context) {
+	for {
+		worked, err := p.runOne(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("jobs: %v", err)
+		}
+		if worked {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// runOne claims and runs a single job. It reports whether a job was found so
+// idle workers can back off.
+func (p *Pool) runOne(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, nil
+	}
+	job, err := p.queue.db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	p.mu.RLock()
+	handler, ok := p.handlers[job.Kind]
+	p.mu.RUnlock()
+	if !ok {
+		return true, p.queue.db.FailJob(ctx, database.FailJobParams{
+			ID:        job.ID,
+			LastError: sql.NullString{String: "no handler for job kind " + job.Kind, Valid: true},
+		})
+	}
+
+	runErr := handler(ctx, job.Payload)
+	// The job's outcome must be recorded even if we are shutting down.
+	recordCtx := context.WithoutCancel(ctx)
+	if runErr == nil {
+		return true, p.queue.db.CompleteJob(recordCtx, job.ID)
+	}
+
+	lastError := sql.NullString{String: runErr.Error(), Valid: true}
+	runAt, retry := nextRun(job.Attempts, job.MaxAttempts, time.Now().UTC())
+	if !retry {
+		log.Printf("jobs: %s job %s failed permanently: %v", job.Kind, job.ID, runErr)
+		return true, p.queue.db.FailJob(recordCtx, database.FailJobParams{
+			ID:        job.ID,
+			LastError: lastError,
+		})
+	}
+	return true, p.queue.db.RetryJob(recordCtx, database.RetryJobParams{
+		ID:        job.ID,
+		RunAt:     runAt,
+		LastError: lastError,
+	})
+}
+
+// nextRun decides what happens to a job that just failed its attempts'th
+// try: it runs again at the returned time, or, once it has used up
+// maxAttempts, not at all.
+func nextRun(attempts, maxAttempts int32, now time.Time) (time.Time, bool) {
+	if attempts >= maxAttempts {
+		return time.Time{}, false
+	}
+	return now.Add(Backoff(attempts)), true
+}
+
+// Backoff is the delay before retrying a job that has failed attempts times:
+// 2s, 4s, 8s, ... capped at ten minutes.
+func Backoff(attempts int32) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	d := time.Second << uint(attempts)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
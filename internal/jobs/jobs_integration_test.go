@@ -0,0 +1,235 @@
+//go:build integration
+
+// These tests run the queue against a throwaway Postgres database. They need
+// CHIRPY_TEST_DB_URL, a URL for a role that may create databases:
+//
+//	CHIRPY_TEST_DB_URL=postgres://postgres@localhost:5432/postgres?sslmode=disable \
+//		go test -tags integration ./internal/jobs
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+// newTestQueue creates a fresh, migrated database and returns a queue on it.
+func newTestQueue(t *testing.T) (*Queue, *database.Queries) {
+	t.Helper()
+	adminURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if adminURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+
+	admin, err := sql.Open("postgres", adminURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	name := "chirpy_test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP DATABASE " + name + " WITH (FORCE)"); err != nil {
+			t.Logf("Couldn't drop %s: %v", name, err)
+		}
+	})
+
+	u, err := url.Parse(adminURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.Path = "/" + name
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	files, err := filepath.Glob("../../sql/schema/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		if _, err := db.Exec(up); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+
+	q := database.New(db)
+	return NewQueue(q), q
+}
+
+func TestClaimHandsEachJobOutOnce(t *testing.T) {
+	queue, db := newTestQueue(t)
+	ctx := context.Background()
+
+	const jobCount = 20
+	for i := 0; i < jobCount; i++ {
+		if err := queue.Enqueue(ctx, "test", i, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = map[uuid.UUID]int{}
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter))
+				if errors.Is(err, sql.ErrNoRows) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				claimed[job.ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(claimed) != jobCount {
+		t.Fatalf("claimed %d distinct jobs, want %d", len(claimed), jobCount)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("job %s was claimed %d times", id, n)
+		}
+	}
+}
+
+func TestClaimReclaimsStaleJobs(t *testing.T) {
+	queue, db := newTestQueue(t)
+	ctx := context.Background()
+
+	if err := queue.Enqueue(ctx, "test", nil, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Attempts != 1 || first.Status != "running" {
+		t.Fatalf("claimed job has attempts=%d status=%s", first.Attempts, first.Status)
+	}
+
+	if _, err := db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter)); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected a running job not to be claimed again, got %v", err)
+	}
+
+	// Pretend the worker that claimed it died long ago.
+	second, err := db.ClaimJob(ctx, time.Now().UTC().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID || second.Attempts != 2 {
+		t.Errorf("expected %s to be reclaimed as attempt 2, got %s attempt %d", first.ID, second.ID, second.Attempts)
+	}
+}
+
+func TestEnqueueDedupes(t *testing.T) {
+	queue, db := newTestQueue(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := queue.Enqueue(ctx, "test", i, Options{DedupeKey: "once"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	job, err := db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(job.Payload) != "0" {
+		t.Errorf("expected the first enqueue to win, got payload %s", job.Payload)
+	}
+
+	// Still deduped while the job is running.
+	if err := queue.Enqueue(ctx, "test", 3, Options{DedupeKey: "once"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter)); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected no second job while the first is running, got %v", err)
+	}
+
+	// A failed job no longer holds the key.
+	err = db.FailJob(ctx, database.FailJobParams{ID: job.ID, LastError: sql.NullString{String: "boom", Valid: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Enqueue(ctx, "test", 4, Options{DedupeKey: "once"}); err != nil {
+		t.Fatal(err)
+	}
+	next, err := db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter))
+	if err != nil {
+		t.Fatalf("expected the key to be free after a failure, got %v", err)
+	}
+	if string(next.Payload) != "4" {
+		t.Errorf("got payload %s, want 4", next.Payload)
+	}
+}
+
+func TestPruneFailed(t *testing.T) {
+	queue, db := newTestQueue(t)
+	ctx := context.Background()
+
+	if err := queue.Enqueue(ctx, "test", nil, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	job, err := db.ClaimJob(ctx, time.Now().UTC().Add(-staleAfter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.FailJob(ctx, database.FailJobParams{ID: job.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := queue.PruneFailed(ctx, time.Now().UTC().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 0 {
+		t.Errorf("pruned %d recent failures, want 0", pruned)
+	}
+
+	pruned, err = queue.PruneFailed(ctx, time.Now().UTC().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("pruned %d old failures, want 1", pruned)
+	}
+}
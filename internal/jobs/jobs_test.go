@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: -1, want: 2 * time.Second},
+		{attempts: 0, want: 2 * time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 2, want: 4 * time.Second},
+		{attempts: 5, want: 32 * time.Second},
+		{attempts: 9, want: 512 * time.Second},
+		{attempts: 10, want: maxBackoff},
+		{attempts: 63, want: maxBackoff},
+		{attempts: 1000, want: maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		attempts    int32
+		maxAttempts int32
+		wantRetry   bool
+		wantRunAt   time.Time
+	}{
+		{name: "first failure", attempts: 1, maxAttempts: 5, wantRetry: true, wantRunAt: now.Add(2 * time.Second)},
+		{name: "later failure", attempts: 3, maxAttempts: 5, wantRetry: true, wantRunAt: now.Add(8 * time.Second)},
+		{name: "last attempt", attempts: 5, maxAttempts: 5, wantRetry: false},
+		{name: "single attempt", attempts: 1, maxAttempts: 1, wantRetry: false},
+		{name: "stale reclaim past the limit", attempts: 6, maxAttempts: 5, wantRetry: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runAt, retry := nextRun(tt.attempts, tt.maxAttempts, now)
+			if retry != tt.wantRetry {
+				t.Fatalf("retry = %v, want %v", retry, tt.wantRetry)
+			}
+			if retry && !runAt.Equal(tt.wantRunAt) {
+				t.Errorf("runAt = %v, want %v", runAt, tt.wantRunAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Package linkpreview fetches the title, description and image of a web
+// page for preview cards. The client it builds refuses to connect to
+// private, loopback and link-local addresses, checked on the address
+// actually dialled so DNS tricks can't route around it, and caps both the
+// time and the bytes spent on each page.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBlockedAddress = errors.New("address not allowed")
+	ErrNotHTML        = errors.New("not an HTML page")
+)
+
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultMaxBytes = 512 << 10
+	maxRedirects    = 3
+	maxFieldLength  = 300
+)
+
+// Preview is what a page says about itself. Any field may be empty.
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Fetcher fetches a preview for a URL. Client is the real implementation;
+// tests can substitute their own.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (Preview, error)
+}
+
+// Client fetches previews over HTTP.
+type Client struct {
+	HTTP     *http.Client
+	MaxBytes int64
+}
+
+// NewClient returns a Client whose connections are restricted to public
+// addresses on ports 80 and 443.
+func NewClient(timeout time.Duration, maxBytes int64) *Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address)
+		},
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &Client{
+		HTTP: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return errors.New("too many redirects")
+				}
+				return checkScheme(req.URL)
+			},
+		},
+		MaxBytes: maxBytes,
+	}
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlockedAddress, u.Scheme)
+	}
+	return nil
+}
+
+// checkAddress allows only public unicast IPs on the standard web ports.
+func checkAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if port != "80" && port != "443" {
+		return fmt.Errorf("%w: port %s", ErrBlockedAddress, port)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !PublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+var nonPublicNets = func() []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // "this" network
+		"100.64.0.0/10", // carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // benchmarking
+		"240.0.0.0/4",   // reserved
+		"64:ff9b::/96",  // NAT64, which can reach private IPv4
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// PublicIP reports whether ip is a globally routable unicast address.
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch downloads at most MaxBytes of the page at rawURL and reads its
+// OpenGraph, Twitter card and plain HTML metadata.
+func (c *Client) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, err
+	}
+	if err := checkScheme(u); err != nil {
+		return Preview{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", "ChirpyLinkPreview/1.0")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, ErrNotHTML
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxBytes))
+	if err != nil {
+		return Preview{}, err
+	}
+	return Parse(resp.Request.URL, string(page)), nil
+}
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attributePattern = regexp.MustCompile(`(?is)([a-z:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// Parse extracts a preview from an HTML page that was served from base.
+// OpenGraph tags win over Twitter card tags, which win over <title> and
+// the description meta tag.
+func Parse(base *url.URL, page string) Preview {
+	meta := map[string]string{}
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		attrs := map[string]string{}
+		for _, m := range attributePattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		if _, seen := meta[key]; key != "" && !seen {
+			meta[key] = attrs["content"]
+		}
+	}
+
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if v := clean(meta[key]); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	p := Preview{
+		URL:         base.String(),
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		SiteName:    first("og:site_name"),
+	}
+	if p.Title == "" {
+		if m := titlePattern.FindStringSubmatch(page); m != nil {
+			p.Title = clean(m[1])
+		}
+	}
+	if image := first("og:image", "og:image:url", "twitter:image"); image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			p.ImageURL = u.String()
+		}
+	}
+	return p
+}
+
+// clean unescapes entities, collapses whitespace and truncates long values.
+func clean(s string) string {
+	s = strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+	if r := []rune(s); len(r) > maxFieldLength {
+		s = string(r[:maxFieldLength-1]) + "…"
+	}
+	return s
+}
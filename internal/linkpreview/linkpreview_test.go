@@ -0,0 +1,153 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const page = `<!doctype html>
+<html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="Herons &amp; Egrets">
+<meta name="description" content="Plain description">
+<meta content='Birds   of the
+  river' property='og:description'>
+<meta property="og:image" content="/img/heron.jpg">
+<meta property="og:site_name" content="Riverside">
+</head><body>hello</body></html>`
+
+// testClient talks to an httptest server, which listens on loopback and
+// would be refused by NewClient's dialer.
+func testClient(srv *httptest.Server) *Client {
+	return &Client{HTTP: srv.Client(), MaxBytes: DefaultMaxBytes}
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/article", http.StatusFound)
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, page)
+		case "/data":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := testClient(srv).Fetch(context.Background(), srv.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Preview{
+		URL:         srv.URL + "/article",
+		Title:       "Herons & Egrets",
+		Description: "Birds of the river",
+		ImageURL:    srv.URL + "/img/heron.jpg",
+		SiteName:    "Riverside",
+	}
+	if p != want {
+		t.Errorf("got %+v\nwant %+v", p, want)
+	}
+
+	if _, err := testClient(srv).Fetch(context.Background(), srv.URL+"/data"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("expected ErrNotHTML, got %v", err)
+	}
+	if _, err := testClient(srv).Fetch(context.Background(), srv.URL+"/missing"); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
+
+func TestFetchReadsAtMostMaxBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head>"+strings.Repeat(" ", 4096)+"<title>Too far down</title>")
+	}))
+	defer srv.Close()
+
+	client := testClient(srv)
+	client.MaxBytes = 1024
+	p, err := client.Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "" {
+		t.Errorf("expected the title past MaxBytes to be ignored, got %q", p.Title)
+	}
+}
+
+func TestNewClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should never reach the server")
+	}))
+	defer srv.Close()
+
+	client := NewClient(DefaultTimeout, DefaultMaxBytes)
+	for _, target := range []string{srv.URL, "http://127.0.0.1/", "http://[::1]/", "http://169.254.169.254/latest/meta-data", "http://10.0.0.1/"} {
+		if _, err := client.Fetch(context.Background(), target); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Fetch(%s) should be blocked, got %v", target, err)
+		}
+	}
+	if _, err := client.Fetch(context.Background(), "file:///etc/passwd"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected file URLs to be blocked, got %v", err)
+	}
+}
+
+func TestNewClientBlocksRedirectsToPrivateAddresses(t *testing.T) {
+	client := NewClient(DefaultTimeout, DefaultMaxBytes)
+	// Check the redirect policy directly; a public server to redirect from
+	// isn't available in tests.
+	req := &http.Request{URL: &url.URL{Scheme: "gopher", Host: "example.com"}}
+	if err := client.HTTP.CheckRedirect(req, nil); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected a redirect to another scheme to be refused, got %v", err)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for _, s := range []string{"93.184.216.34", "1.1.1.1", "2606:4700:4700::1111"} {
+		if !PublicIP(net.ParseIP(s)) {
+			t.Errorf("expected %s to be public", s)
+		}
+	}
+	for _, s := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fc00::1", "::ffff:127.0.0.1",
+		"64:ff9b::a00:1", "224.0.0.1",
+	} {
+		if PublicIP(net.ParseIP(s)) {
+			t.Errorf("expected %s to be blocked", s)
+		}
+	}
+}
+
+func TestCheckAddressPorts(t *testing.T) {
+	if err := checkAddress("93.184.216.34:443"); err != nil {
+		t.Errorf("expected port 443 to be allowed, got %v", err)
+	}
+	if err := checkAddress("93.184.216.34:6379"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected other ports to be blocked, got %v", err)
+	}
+}
+
+func TestParseFallsBack(t *testing.T) {
+	base, _ := url.Parse("https://example.com/post")
+	p := Parse(base, `<TITLE> Just a
+	title </TITLE><META NAME="Description" CONTENT="Desc"><meta name="twitter:image" content="javascript:alert(1)">`)
+	if p.Title != "Just a title" || p.Description != "Desc" {
+		t.Errorf("unexpected fallback preview %+v", p)
+	}
+	if p.ImageURL != "" {
+		t.Errorf("expected non-http image to be dropped, got %q", p.ImageURL)
+	}
+}
@@ -0,0 +1,321 @@
+// Package live runs the sessions behind the WebSocket API. A client joins
+// named channels and receives the stream.Hub events published on them as
+// JSON messages. Sessions talk to clients through the Conn interface, so
+// they can be driven by in-memory connections in tests.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/stream"
+	"workspace/github.com/Benjysparks/chirpy/internal/websocket"
+)
+
+const (
+	DefaultPingInterval = 30 * time.Second
+	// MaxChannels is how many channels one session can join.
+	MaxChannels = 20
+	// replyBuffer is how many replies to client messages can be queued
+	// before the client is considered too slow.
+	replyBuffer = 16
+)
+
+var (
+	ErrUnauthorized   = errors.New("authentication required")
+	ErrUnknownChannel = errors.New("unknown channel")
+)
+
+// Conn is a client connection that carries whole messages.
+type Conn interface {
+	// ReadMessage blocks until the client sends a message.
+	ReadMessage() ([]byte, error)
+	// WriteMessage sends a message, giving up on a client that doesn't
+	// take it in reasonable time.
+	WriteMessage([]byte) error
+	Ping() error
+	// Close disconnects the client with a WebSocket close code. It must be
+	// safe to call while WriteMessage or ReadMessage is blocked.
+	Close(code int, reason string) error
+}
+
+// Server runs sessions. The zero value is not usable: Hub, Authenticate and
+// Channel must be set.
+type Server struct {
+	Hub *stream.Hub
+	// Authenticate resolves the token in an auth message to a user.
+	Authenticate func(ctx context.Context, token string) (uuid.UUID, error)
+	// Channel returns which events belong to a channel the user asked to
+	// join, or ErrUnauthorized or ErrUnknownChannel. userID is uuid.Nil for
+	// clients that haven't authenticated.
+	Channel      func(ctx context.Context, userID uuid.UUID, name string) (stream.Filter, error)
+	PingInterval time.Duration
+
+	mu       sync.Mutex
+	sessions map[*session]struct{}
+	closing  bool
+	wg       sync.WaitGroup
+}
+
+// clientMessage is anything a client sends: auth with a token, subscribe
+// or unsubscribe with a channel, or ping.
+type clientMessage struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Token   string `json:"token"`
+}
+
+type serverMessage struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	ID      int64           `json:"id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+type session struct {
+	srv    *Server
+	conn   Conn
+	userID uuid.UUID
+	// channels is replaced whole when the client joins or leaves one,
+	// because the hub reads it while publishing.
+	channels  atomic.Pointer[map[string]stream.Filter]
+	replies   chan serverMessage
+	closeOnce sync.Once
+}
+
+// Serve runs a session until the client disconnects, falls too far behind
+// or the server shuts down. userID is the user the connection was opened
+// as, or uuid.Nil; clients can also authenticate with an auth message.
+func (s *Server) Serve(ctx context.Context, conn Conn, userID uuid.UUID) {
+	sess := &session{srv: s, conn: conn, userID: userID, replies: make(chan serverMessage, replyBuffer)}
+	sess.channels.Store(&map[string]stream.Filter{})
+	if !s.add(sess) {
+		conn.Close(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer s.remove(sess)
+
+	sub, _, _ := s.Hub.Subscribe(0, sess.wants)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		sess.writeLoop(ctx, sub)
+	}()
+	sess.readLoop(ctx)
+	cancel()
+	<-written
+	sess.close(websocket.CloseNormal, "")
+}
+
+// Shutdown disconnects every session with CloseGoingAway and waits for them
+// to finish. Sessions started afterwards are turned away.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	for sess := range s.sessions {
+		go sess.close(websocket.CloseGoingAway, "server shutting down")
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sessions returns how many sessions are running.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+func (s *Server) add(sess *session) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	if s.sessions == nil {
+		s.sessions = map[*session]struct{}{}
+	}
+	s.sessions[sess] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) remove(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sess)
+	s.wg.Done()
+}
+
+func (s *Server) pingInterval() time.Duration {
+	if s.PingInterval > 0 {
+		return s.PingInterval
+	}
+	return DefaultPingInterval
+}
+
+// wants reports whether any of the session's channels takes the event.
+func (sess *session) wants(e stream.Event) bool {
+	for _, filter := range *sess.channels.Load() {
+		if filter(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sess *session) readLoop(ctx context.Context) {
+	for {
+		data, err := sess.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			sess.reply(serverMessage{Type: "error", Error: "invalid message"})
+			continue
+		}
+
+		switch msg.Type {
+		case "auth":
+			sess.authenticate(ctx, msg.Token)
+		case "subscribe":
+			sess.subscribe(ctx, msg.Channel)
+		case "unsubscribe":
+			channels := sess.copyChannels()
+			delete(channels, msg.Channel)
+			sess.channels.Store(&channels)
+			sess.reply(serverMessage{Type: "unsubscribed", Channel: msg.Channel})
+		case "ping":
+			sess.reply(serverMessage{Type: "pong"})
+		default:
+			sess.reply(serverMessage{Type: "error", Error: "unknown message type"})
+		}
+	}
+}
+
+// authenticate only upgrades an anonymous session; channels joined before
+// keep their anonymous view until they're joined again.
+func (sess *session) authenticate(ctx context.Context, token string) {
+	if sess.userID != uuid.Nil {
+		sess.reply(serverMessage{Type: "error", Error: "already authenticated"})
+		return
+	}
+	userID, err := sess.srv.Authenticate(ctx, token)
+	if err != nil {
+		sess.reply(serverMessage{Type: "error", Error: "invalid token"})
+		return
+	}
+	sess.userID = userID
+	sess.reply(serverMessage{Type: "authenticated"})
+}
+
+func (sess *session) subscribe(ctx context.Context, name string) {
+	channels := sess.copyChannels()
+	if len(channels) >= MaxChannels {
+		sess.reply(serverMessage{Type: "error", Channel: name, Error: "too many channels"})
+		return
+	}
+
+	filter, err := sess.srv.Channel(ctx, sess.userID, name)
+	if err != nil {
+		msg := "couldn't subscribe"
+		if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrUnknownChannel) {
+			msg = err.Error()
+		}
+		sess.reply(serverMessage{Type: "error", Channel: name, Error: msg})
+		return
+	}
+	channels[name] = filter
+	sess.channels.Store(&channels)
+	sess.reply(serverMessage{Type: "subscribed", Channel: name})
+}
+
+func (sess *session) copyChannels() map[string]stream.Filter {
+	channels := map[string]stream.Filter{}
+	for name, filter := range *sess.channels.Load() {
+		channels[name] = filter
+	}
+	return channels
+}
+
+// reply queues a message for the writer. A client that has let the queue
+// fill up isn't reading, so it is disconnected.
+func (sess *session) reply(msg serverMessage) {
+	select {
+	case sess.replies <- msg:
+	default:
+		sess.close(websocket.CloseTryAgainLater, "too slow")
+	}
+}
+
+// writeLoop is the only goroutine that writes messages, so a client that
+// stops reading holds up nobody else. If it falls far enough behind, the
+// hub drops its subscription and the session ends.
+func (sess *session) writeLoop(ctx context.Context, sub *stream.Subscription) {
+	ping := time.NewTicker(sess.srv.pingInterval())
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-sess.replies:
+			err = sess.write(msg)
+		case e, ok := <-sub.C:
+			if !ok {
+				sess.close(websocket.CloseTryAgainLater, "too slow")
+				return
+			}
+			for name, filter := range *sess.channels.Load() {
+				if err == nil && filter(e) {
+					err = sess.write(serverMessage{Type: "event", Channel: name, ID: e.ID, Event: e.Type, Data: e.Data})
+				}
+			}
+		case <-ping.C:
+			err = sess.conn.Ping()
+		}
+		if err != nil {
+			sess.close(websocket.CloseNormal, "")
+			return
+		}
+	}
+}
+
+func (sess *session) write(msg serverMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return sess.conn.WriteMessage(data)
+}
+
+// close disconnects the client, which also ends the read loop. Only the
+// first call has any effect.
+func (sess *session) close(code int, reason string) {
+	sess.closeOnce.Do(func() {
+		sess.conn.Close(code, reason)
+	})
+}
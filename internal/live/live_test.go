@@ -0,0 +1,237 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/stream"
+	"workspace/github.com/Benjysparks/chirpy/internal/websocket"
+)
+
+// memConn is an in-memory client connection. The test plays the client by
+// sending on in and receiving from out.
+type memConn struct {
+	in     chan []byte
+	out    chan []byte
+	closed chan struct{}
+	once   sync.Once
+	code   int
+}
+
+func newMemConn(buffer int) *memConn {
+	return &memConn{in: make(chan []byte), out: make(chan []byte, buffer), closed: make(chan struct{})}
+}
+
+func (c *memConn) ReadMessage() ([]byte, error) {
+	select {
+	case data := <-c.in:
+		return data, nil
+	case <-c.closed:
+		return nil, errors.New("closed")
+	}
+}
+
+func (c *memConn) WriteMessage(data []byte) error {
+	select {
+	case c.out <- data:
+		return nil
+	case <-c.closed:
+		return errors.New("closed")
+	}
+}
+
+func (c *memConn) Ping() error { return nil }
+
+func (c *memConn) Close(code int, reason string) error {
+	c.once.Do(func() {
+		c.code = code
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *memConn) send(t *testing.T, msg clientMessage) {
+	t.Helper()
+	data, _ := json.Marshal(msg)
+	select {
+	case c.in <- data:
+	case <-time.After(time.Second):
+		t.Fatal("session isn't reading")
+	}
+}
+
+func (c *memConn) receive(t *testing.T) serverMessage {
+	t.Helper()
+	select {
+	case data := <-c.out:
+		var msg serverMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("expected a message")
+	}
+	return serverMessage{}
+}
+
+var alice = uuid.New()
+
+func newServer() *Server {
+	return &Server{
+		Hub: stream.NewHub(stream.DefaultBacklog),
+		Authenticate: func(ctx context.Context, token string) (uuid.UUID, error) {
+			if token != "alice" {
+				return uuid.Nil, errors.New("bad token")
+			}
+			return alice, nil
+		},
+		Channel: func(ctx context.Context, userID uuid.UUID, name string) (stream.Filter, error) {
+			switch name {
+			case "feed":
+				return func(e stream.Event) bool { return e.Recipient == uuid.Nil }, nil
+			case "notifications":
+				if userID == uuid.Nil {
+					return nil, ErrUnauthorized
+				}
+				return func(e stream.Event) bool { return e.Recipient == userID }, nil
+			}
+			return nil, ErrUnknownChannel
+		},
+	}
+}
+
+func start(srv *Server, conn *memConn, userID uuid.UUID) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		srv.Serve(context.Background(), conn, userID)
+		close(done)
+	}()
+	return done
+}
+
+func wait(t *testing.T, done chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("session didn't end")
+	}
+}
+
+func TestSubscribeAndReceive(t *testing.T) {
+	srv := newServer()
+	conn := newMemConn(16)
+	done := start(srv, conn, uuid.Nil)
+
+	conn.send(t, clientMessage{Type: "subscribe", Channel: "feed"})
+	if msg := conn.receive(t); msg.Type != "subscribed" || msg.Channel != "feed" {
+		t.Fatalf("unexpected reply %+v", msg)
+	}
+	srv.Hub.Publish(context.Background(), stream.Event{Type: "chirp.created", Data: json.RawMessage(`{"body":"hi"}`)})
+	msg := conn.receive(t)
+	if msg.Type != "event" || msg.Channel != "feed" || msg.Event != "chirp.created" || string(msg.Data) != `{"body":"hi"}` {
+		t.Errorf("unexpected event %+v", msg)
+	}
+
+	conn.send(t, clientMessage{Type: "unsubscribe", Channel: "feed"})
+	if msg := conn.receive(t); msg.Type != "unsubscribed" {
+		t.Fatalf("unexpected reply %+v", msg)
+	}
+	srv.Hub.Publish(context.Background(), stream.Event{Type: "chirp.created"})
+	conn.send(t, clientMessage{Type: "ping"})
+	if msg := conn.receive(t); msg.Type != "pong" {
+		t.Errorf("expected nothing but the pong after unsubscribing, got %+v", msg)
+	}
+
+	conn.Close(websocket.CloseNormal, "")
+	wait(t, done)
+	if srv.Sessions() != 0 {
+		t.Error("expected the session to be removed")
+	}
+}
+
+func TestPrivateChannelsNeedAuthentication(t *testing.T) {
+	srv := newServer()
+	conn := newMemConn(16)
+	done := start(srv, conn, uuid.Nil)
+	defer wait(t, done)
+	defer conn.Close(websocket.CloseNormal, "")
+
+	conn.send(t, clientMessage{Type: "subscribe", Channel: "notifications"})
+	if msg := conn.receive(t); msg.Type != "error" || msg.Error != ErrUnauthorized.Error() {
+		t.Fatalf("expected an auth error, got %+v", msg)
+	}
+	conn.send(t, clientMessage{Type: "auth", Token: "mallory"})
+	if msg := conn.receive(t); msg.Type != "error" {
+		t.Fatalf("expected a bad token to be rejected, got %+v", msg)
+	}
+	conn.send(t, clientMessage{Type: "auth", Token: "alice"})
+	if msg := conn.receive(t); msg.Type != "authenticated" {
+		t.Fatalf("unexpected reply %+v", msg)
+	}
+	conn.send(t, clientMessage{Type: "subscribe", Channel: "notifications"})
+	if msg := conn.receive(t); msg.Type != "subscribed" {
+		t.Fatalf("unexpected reply %+v", msg)
+	}
+
+	srv.Hub.Publish(context.Background(), stream.Event{Type: "notification", Recipient: uuid.New()})
+	srv.Hub.Publish(context.Background(), stream.Event{Type: "notification", Recipient: alice})
+	if msg := conn.receive(t); msg.Type != "event" || msg.ID != 2 {
+		t.Errorf("expected only alice's notification, got %+v", msg)
+	}
+}
+
+func TestSlowClientIsDisconnected(t *testing.T) {
+	srv := newServer()
+	conn := newMemConn(0)
+	done := start(srv, conn, uuid.Nil)
+
+	conn.send(t, clientMessage{Type: "subscribe", Channel: "feed"})
+	conn.receive(t)
+	// The writer blocks on the first event, and the rest pile up until the
+	// hub gives up on the subscription.
+	for i := 0; i < 100; i++ {
+		srv.Hub.Publish(context.Background(), stream.Event{Type: "chirp.created"})
+	}
+	for {
+		select {
+		case <-conn.out:
+			continue
+		case <-done:
+		}
+		break
+	}
+	if conn.code != websocket.CloseTryAgainLater {
+		t.Errorf("expected CloseTryAgainLater, got %d", conn.code)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	srv := newServer()
+	conn := newMemConn(16)
+	done := start(srv, conn, uuid.Nil)
+	conn.send(t, clientMessage{Type: "ping"})
+	conn.receive(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, done)
+	if conn.code != websocket.CloseGoingAway {
+		t.Errorf("expected CloseGoingAway, got %d", conn.code)
+	}
+
+	late := newMemConn(16)
+	wait(t, start(srv, late, uuid.Nil))
+	if late.code != websocket.CloseGoingAway {
+		t.Error("expected sessions after shutdown to be turned away")
+	}
+}
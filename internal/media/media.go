@@ -0,0 +1,335 @@
+// Package media checks uploaded images and prepares them for serving. The
+// type comes from the file's magic bytes, never from the client, and every
+// image is decoded and re-encoded, which drops EXIF, comments and any other
+// metadata the original carried.
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	GIF  = "image/gif"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooLarge        = errors.New("image is too large")
+)
+
+// Limits bounds what Process accepts. MaxPixels protects against small
+// files that decode to enormous images; for a GIF it covers every frame.
+type Limits struct {
+	MaxBytes  int64
+	MaxPixels int
+	// ThumbnailSize is the longest side of a thumbnail, in pixels.
+	ThumbnailSize int
+}
+
+var DefaultLimits = Limits{
+	MaxBytes:      5 << 20,
+	MaxPixels:     40_000_000,
+	ThumbnailSize: 320,
+}
+
+// Image is a processed upload, ready to store.
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Detect returns the content type of data from its magic bytes.
+func Detect(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return JPEG, nil
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return PNG, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return GIF, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// Process validates an upload and returns it stripped of metadata, along
+// with a thumbnail. JPEGs are turned upright according to their EXIF
+// orientation before it is discarded.
+func Process(data []byte, limits Limits) (Image, error) {
+	if int64(len(data)) > limits.MaxBytes {
+		return Image{}, ErrTooLarge
+	}
+	contentType, err := Detect(data)
+	if err != nil {
+		return Image{}, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("couldn't read image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > limits.MaxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	out := Image{ContentType: contentType}
+	var first image.Image
+	buf := &bytes.Buffer{}
+
+	switch contentType {
+	case JPEG:
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		img = orient(img, jpegOrientation(data))
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return Image{}, err
+		}
+		first = img
+	case PNG:
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		if err := png.Encode(buf, img); err != nil {
+			return Image{}, err
+		}
+		first = img
+	case GIF:
+		// Keep every frame so animations survive; the encoder only writes
+		// frames, timing and the loop count. Frames are counted before
+		// decoding, since decoding allocates all of them at once.
+		if gifFrameCount(data)*config.Width*config.Height > limits.MaxPixels {
+			return Image{}, ErrTooLarge
+		}
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("couldn't decode image: %w", err)
+		}
+		if err := gif.EncodeAll(buf, anim); err != nil {
+			return Image{}, err
+		}
+		first = anim.Image[0]
+	}
+	out.Data = buf.Bytes()
+	out.Width = first.Bounds().Dx()
+	out.Height = first.Bounds().Dy()
+
+	thumb := Thumbnail(first, limits.ThumbnailSize)
+	buf = &bytes.Buffer{}
+	if contentType == JPEG {
+		err = jpeg.Encode(buf, thumb, &jpeg.Options{Quality: 80})
+		out.ThumbnailContentType = JPEG
+	} else {
+		err = png.Encode(buf, thumb)
+		out.ThumbnailContentType = PNG
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	out.Thumbnail = buf.Bytes()
+	return out, nil
+}
+
+// Thumbnail scales img down so its longest side is at most size, averaging
+// the source pixels behind each thumbnail pixel. Smaller images are copied
+// unscaled.
+func Thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	if tw == w && th == h {
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+		return dst
+	}
+
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					c := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// gifFrameCount walks a GIF's blocks without decoding any pixels and
+// returns how many image descriptors it holds. A truncated file is counted
+// up to where it ends; the decoder rejects it afterwards.
+func gifFrameCount(data []byte) int {
+	// skipSubBlocks returns the offset just past a chain of data
+	// sub-blocks, or -1 if data ends first.
+	skipSubBlocks := func(i int) int {
+		for i < len(data) {
+			size := int(data[i])
+			i++
+			if size == 0 {
+				return i
+			}
+			i += size
+		}
+		return -1
+	}
+	colorTable := func(flags byte) int {
+		if flags&0x80 == 0 {
+			return 0
+		}
+		return 3 << (flags&0x07 + 1)
+	}
+
+	const headerLen, screenLen, descriptorLen = 6, 7, 9
+	i := headerLen + screenLen
+	if i > len(data) {
+		return 0
+	}
+	i += colorTable(data[headerLen+4])
+
+	frames := 0
+	for i >= 0 && i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: label, then sub-blocks
+			i = skipSubBlocks(i + 2)
+		case 0x2C: // image descriptor, local color table, LZW code size
+			if i+1+descriptorLen > len(data) {
+				return frames
+			}
+			frames++
+			i += 1 + descriptorLen + colorTable(data[i+descriptorLen]) + 1
+			i = skipSubBlocks(i)
+		default: // trailer or garbage
+			return frames
+		}
+	}
+	return frames
+}
+
+// jpegOrientation returns the EXIF orientation tag of a JPEG, or 1 when it
+// has none.
+func jpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		const orientationTag, typeShort = 0x0112, 3
+		if order.Uint16(tiff[entry:]) == orientationTag && order.Uint16(tiff[entry+2:]) == typeShort {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+		}
+	}
+	return 1
+}
+
+// orient applies an EXIF orientation so the image displays upright.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
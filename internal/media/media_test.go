@@ -0,0 +1,226 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an APP1 segment carrying an orientation tag and a
+// camera make right after the JPEG's start-of-image marker.
+func withExif(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+	tiff := &bytes.Buffer{}
+	tiff.WriteString("II")
+	binary.Write(tiff, binary.LittleEndian, uint16(42))
+	binary.Write(tiff, binary.LittleEndian, uint32(8))
+	binary.Write(tiff, binary.LittleEndian, uint16(1))
+	binary.Write(tiff, binary.LittleEndian, []uint16{0x0112, 3})
+	binary.Write(tiff, binary.LittleEndian, uint32(1))
+	binary.Write(tiff, binary.LittleEndian, []uint16{orientation, 0})
+	binary.Write(tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString("SecretCamera GPS 51.5N")
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"\xFF\xD8\xFF\xE0rest":  JPEG,
+		"\x89PNG\r\n\x1a\nrest": PNG,
+		"GIF89a rest":           GIF,
+		"GIF87a rest":           GIF,
+	}
+	for data, want := range cases {
+		got, err := Detect([]byte(data))
+		if err != nil || got != want {
+			t.Errorf("Detect(%q) = %q, %v; want %q", data, got, err, want)
+		}
+	}
+
+	for _, data := range []string{"", "<svg></svg>", "RIFF\x00\x00\x00\x00WEBPVP8 ", "%PDF-1.7"} {
+		if _, err := Detect([]byte(data)); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("Detect(%q) should be unsupported, got %v", data, err)
+		}
+	}
+}
+
+func TestProcessStripsExifAndRotates(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, testImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := withExif(t, buf.Bytes(), 6)
+	if jpegOrientation(data) != 6 {
+		t.Fatal("test image should carry orientation 6")
+	}
+
+	out, err := Process(data, DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ContentType != JPEG || out.ThumbnailContentType != JPEG {
+		t.Errorf("unexpected content types %q, %q", out.ContentType, out.ThumbnailContentType)
+	}
+	if bytes.Contains(out.Data, []byte("Exif")) || bytes.Contains(out.Data, []byte("SecretCamera")) {
+		t.Error("expected EXIF to be stripped")
+	}
+	if out.Width != 20 || out.Height != 40 {
+		t.Errorf("expected a rotated 20x40 image, got %dx%d", out.Width, out.Height)
+	}
+}
+
+func TestProcessStripsPNGText(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, testImage(10, 10)); err != nil {
+		t.Fatal(err)
+	}
+	// Insert a tEXt chunk after IHDR (8-byte signature + 25-byte chunk).
+	body := append([]byte("tEXt"), "Comment\x00taken at home"...)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)-4))
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(body))
+	data := append(append(append([]byte{}, buf.Bytes()[:33]...), chunk...), buf.Bytes()[33:]...)
+
+	out, err := Process(data, DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(out.Data, []byte("taken at home")) {
+		t.Error("expected text chunk to be stripped")
+	}
+	if out.ThumbnailContentType != PNG {
+		t.Errorf("expected a PNG thumbnail, got %q", out.ThumbnailContentType)
+	}
+}
+
+func TestProcessKeepsGIFFrames(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{LoopCount: 0}
+	for i := 0; i < 3; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 8, 8), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	buf := &bytes.Buffer{}
+	if err := gif.EncodeAll(buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Process(buf.Bytes(), DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(out.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Image) != 3 {
+		t.Errorf("expected 3 frames, got %d", len(decoded.Image))
+	}
+}
+
+func TestProcessRejectsManyFrameGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := 0; i < 500; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 64, 64), palette))
+		anim.Delay = append(anim.Delay, 1)
+	}
+	buf := &bytes.Buffer{}
+	if err := gif.EncodeAll(buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	if n := gifFrameCount(buf.Bytes()); n != 500 {
+		t.Errorf("expected 500 frames, got %d", n)
+	}
+
+	// Each frame fits the limit on its own, but all of them together don't.
+	limits := Limits{MaxBytes: 1 << 20, MaxPixels: 1_000_000, ThumbnailSize: 32}
+	if _, err := Process(buf.Bytes(), limits); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected frame pixel limit, got %v", err)
+	}
+
+	// Frames whose pixel data is garbage would fail to decode, so getting
+	// ErrTooLarge back shows the frames were counted before decoding.
+	crafted := []byte("GIF89a\x40\x00\x40\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff")
+	for i := 0; i < 500; i++ {
+		crafted = append(crafted, 0x2C, 0, 0, 0, 0, 0x40, 0, 0x40, 0, 0, 2, 1, 0xFF, 0)
+	}
+	crafted = append(crafted, 0x3B)
+	if n := gifFrameCount(crafted); n != 500 {
+		t.Errorf("expected 500 crafted frames, got %d", n)
+	}
+	if _, err := Process(crafted, limits); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected crafted GIF to hit the pixel limit, got %v", err)
+	}
+}
+
+func TestProcessLimits(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, testImage(100, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Process(buf.Bytes(), Limits{MaxBytes: 10, MaxPixels: 1 << 20, ThumbnailSize: 32}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected file size limit, got %v", err)
+	}
+	if _, err := Process(buf.Bytes(), Limits{MaxBytes: 1 << 20, MaxPixels: 5000, ThumbnailSize: 32}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected pixel limit, got %v", err)
+	}
+	if _, err := Process([]byte("\x89PNG\r\n\x1a\ngarbage"), DefaultLimits); err == nil {
+		t.Error("expected a corrupt image to fail")
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	cases := []struct{ w, h, wantW, wantH int }{
+		{640, 480, 320, 240},
+		{300, 1200, 80, 320},
+		{100, 50, 100, 50},
+		{5000, 2, 320, 1},
+	}
+	for _, c := range cases {
+		got := Thumbnail(testImage(c.w, c.h), 320).Bounds()
+		if got.Dx() != c.wantW || got.Dy() != c.wantH {
+			t.Errorf("Thumbnail(%dx%d) = %dx%d, want %dx%d", c.w, c.h, got.Dx(), got.Dy(), c.wantW, c.wantH)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	src := testImage(3, 2)
+	// Orientation 8 means the camera was turned the other way, so the top
+	// right corner of the stored image belongs at the top left.
+	got := orient(src, 8)
+	if got.Bounds().Dx() != 2 || got.Bounds().Dy() != 3 {
+		t.Fatalf("unexpected bounds %v", got.Bounds())
+	}
+	if got.At(0, 0) != src.At(2, 0) {
+		t.Errorf("expected top-left pixel from (2,0), got %v", got.At(0, 0))
+	}
+	if orient(src, 1) != image.Image(src) {
+		t.Error("expected orientation 1 to leave the image alone")
+	}
+}
@@ -0,0 +1,336 @@
+// Package moderation checks chirp bodies against an ordered pipeline of
+// filters. Each filter decides what happens to text it matches: masking
+// replaces the match in place, holding sends the chirp to a review queue,
+// and rejecting refuses it outright.
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+type Action string
+
+const (
+	ActionAllow  Action = "allow"
+	ActionMask   Action = "mask"
+	ActionHold   Action = "hold"
+	ActionReject Action = "reject"
+)
+
+// severity orders actions so the strictest one wins.
+var severity = map[Action]int{
+	ActionAllow:  0,
+	ActionMask:   1,
+	ActionHold:   2,
+	ActionReject: 3,
+}
+
+// ValidAction reports whether a is an action a filter may take.
+func ValidAction(a Action) bool {
+	return a == ActionMask || a == ActionHold || a == ActionReject
+}
+
+type Kind string
+
+const (
+	KindWordList Kind = "word_list"
+	KindRegex    Kind = "regex"
+	KindLink     Kind = "link"
+)
+
+// Mask is what masked text is replaced with.
+const Mask = "****"
+
+// Config describes one filter as stored by the admin API. Terms holds the
+// words of a word list or the blocked domains of a link filter; Pattern
+// holds the expression of a regex filter.
+type Config struct {
+	ID      string
+	Name    string
+	Kind    Kind
+	Action  Action
+	Pattern string
+	Terms   []string
+}
+
+// Match records a filter that fired.
+type Match struct {
+	FilterID string `json:"filter_id"`
+	Filter   string `json:"filter"`
+	Action   Action `json:"action"`
+}
+
+// Result is the outcome of running a body through a pipeline.
+type Result struct {
+	// Text is the body with every masking filter applied.
+	Text    string
+	Action  Action
+	Matches []Match
+}
+
+// Reasons is a human-readable summary of the filters that fired.
+func (r Result) Reasons() string {
+	names := []string{}
+	for _, m := range r.Matches {
+		names = append(names, m.Filter)
+	}
+	return strings.Join(names, ", ")
+}
+
+type filter interface {
+	// apply returns the text with any matches masked and whether the filter
+	// matched at all.
+	apply(text string) (string, bool)
+}
+
+type stage struct {
+	config Config
+	filter filter
+}
+
+// Pipeline runs filters in order. Each filter sees the output of the one
+// before it, so a word masked early can't trip a later filter.
+type Pipeline struct {
+	stages []stage
+}
+
+// NewPipeline compiles configs into a pipeline, keeping their order.
+func NewPipeline(configs []Config) (*Pipeline, error) {
+	p := &Pipeline{}
+	for _, c := range configs {
+		f, err := compile(c)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", c.Name, err)
+		}
+		p.stages = append(p.stages, stage{config: c, filter: f})
+	}
+	return p, nil
+}
+
+// Validate reports whether c would compile into a working filter.
+func Validate(c Config) error {
+	_, err := compile(c)
+	return err
+}
+
+func compile(c Config) (filter, error) {
+	if !ValidAction(c.Action) {
+		return nil, fmt.Errorf("unknown action %q", c.Action)
+	}
+	switch c.Kind {
+	case KindWordList:
+		return newWordListFilter(c.Terms), nil
+	case KindRegex:
+		if c.Pattern == "" {
+			return nil, errors.New("regex filters need a pattern")
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return regexFilter{re: re}, nil
+	case KindLink:
+		return newLinkFilter(c.Terms), nil
+	}
+	return nil, fmt.Errorf("unknown filter kind %q", c.Kind)
+}
+
+// Check runs text through every filter. Masking filters rewrite the text;
+// the first rejecting filter stops the pipeline.
+func (p *Pipeline) Check(text string) Result {
+	result := Result{Text: text, Action: ActionAllow, Matches: []Match{}}
+	for _, s := range p.stages {
+		masked, matched := s.filter.apply(result.Text)
+		if !matched {
+			continue
+		}
+		result.Matches = append(result.Matches, Match{
+			FilterID: s.config.ID,
+			Filter:   s.config.Name,
+			Action:   s.config.Action,
+		})
+		if s.config.Action == ActionMask {
+			result.Text = masked
+		}
+		if severity[s.config.Action] > severity[result.Action] {
+			result.Action = s.config.Action
+		}
+		if result.Action == ActionReject {
+			break
+		}
+	}
+	return result
+}
+
+// Live holds the pipeline currently in use and lets it be swapped while
+// requests are being served.
+type Live struct {
+	current atomic.Pointer[Pipeline]
+}
+
+func NewLive() *Live {
+	l := &Live{}
+	l.current.Store(&Pipeline{})
+	return l
+}
+
+func (l *Live) Pipeline() *Pipeline {
+	return l.current.Load()
+}
+
+func (l *Live) Swap(p *Pipeline) {
+	l.current.Store(p)
+}
+
+// wordListFilter masks whole words, comparing them after normalization so
+// "Kerfuffle!", "KERFUFFLE" and "k3rfuffl3" all match "kerfuffle".
+type wordListFilter struct {
+	words map[string]bool
+}
+
+func newWordListFilter(terms []string) wordListFilter {
+	words := map[string]bool{}
+	for _, t := range terms {
+		if n := Normalize(t); n != "" {
+			words[n] = true
+		}
+	}
+	return wordListFilter{words: words}
+}
+
+func (f wordListFilter) apply(text string) (string, bool) {
+	var out strings.Builder
+	matched := false
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			out.WriteRune(runes[i])
+			i++
+			continue
+		}
+		end := i
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		word := string(runes[i:end])
+		if f.words[Normalize(word)] {
+			out.WriteString(Mask)
+			matched = true
+		} else {
+			out.WriteString(word)
+		}
+		i = end
+	}
+	return out.String(), matched
+}
+
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+}
+
+// Normalize lowercases a word, undoes common digit-for-letter substitutions
+// and drops anything that isn't a letter.
+func Normalize(word string) string {
+	var b strings.Builder
+	for _, r := range word {
+		if sub, ok := leet[r]; ok {
+			r = sub
+		}
+		if unicode.IsLetter(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type regexFilter struct {
+	re *regexp.Regexp
+}
+
+func (f regexFilter) apply(text string) (string, bool) {
+	if !f.re.MatchString(text) {
+		return text, false
+	}
+	return f.re.ReplaceAllLiteralString(text, Mask), true
+}
+
+// linkFilter matches URLs pointing at a blocked domain or any of its
+// subdomains. A term of "*" matches every link.
+type linkFilter struct {
+	domains map[string]bool
+	any     bool
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+|\bwww\.[^\s<>"]+`)
+
+func newLinkFilter(terms []string) linkFilter {
+	f := linkFilter{domains: map[string]bool{}}
+	for _, t := range terms {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "*" {
+			f.any = true
+			continue
+		}
+		if t != "" {
+			f.domains[strings.TrimPrefix(t, "www.")] = true
+		}
+	}
+	return f
+}
+
+func (f linkFilter) apply(text string) (string, bool) {
+	matched := false
+	masked := linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		if f.blocked(link) {
+			matched = true
+			return Mask
+		}
+		return link
+	})
+	return masked, matched
+}
+
+func (f linkFilter) blocked(link string) bool {
+	if f.any {
+		return true
+	}
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(strings.TrimRight(link, ".,!?;:)"))
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	for host != "" {
+		if f.domains[host] {
+			return true
+		}
+		_, rest, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = rest
+	}
+	return false
+}
+
+// Links returns the URLs found in text.
+func Links(text string) []string {
+	return linkPattern.FindAllString(text, -1)
+}
@@ -0,0 +1,78 @@
+package moderation
+
+import "testing"
+
+func profanity(action Action) Config {
+	return Config{
+		ID:     "1",
+		Name:   "profanity",
+		Kind:   KindWordList,
+		Action: action,
+		Terms:  []string{"kerfuffle", "sharbert", "fornax"},
+	}
+}
+
+func TestWordListMasksThroughPunctuationAndCase(t *testing.T) {
+	p, err := NewPipeline([]Config{profanity(ActionMask)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := p.Check("What a Kerfuffle! Such k3rfuffl3, much SHARBERT.")
+	want := "What a ****! Such ****, much ****."
+	if got.Text != want {
+		t.Errorf("got %q, want %q", got.Text, want)
+	}
+	if got.Action != ActionMask {
+		t.Errorf("got action %q, want mask", got.Action)
+	}
+}
+
+func TestStrictestActionWins(t *testing.T) {
+	p, err := NewPipeline([]Config{
+		profanity(ActionMask),
+		{ID: "2", Name: "crypto scam", Kind: KindRegex, Action: ActionHold, Pattern: `(?i)free\s+bitcoin`},
+		{ID: "3", Name: "blocked sites", Kind: KindLink, Action: ActionReject, Terms: []string{"evil.example"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	held := p.Check("FREE bitcoin, what a fornax")
+	if held.Action != ActionHold || len(held.Matches) != 2 {
+		t.Errorf("expected hold with two matches, got %+v", held)
+	}
+
+	rejected := p.Check("see https://cdn.evil.example/x and free bitcoin")
+	if rejected.Action != ActionReject {
+		t.Errorf("expected reject, got %+v", rejected)
+	}
+
+	clean := p.Check("see https://good.example/evil.example")
+	if clean.Action != ActionAllow || clean.Text != "see https://good.example/evil.example" {
+		t.Errorf("expected clean text to pass, got %+v", clean)
+	}
+}
+
+func TestMaskedWordsDontTripLaterFilters(t *testing.T) {
+	p, err := NewPipeline([]Config{
+		profanity(ActionMask),
+		{ID: "2", Name: "no fornax", Kind: KindRegex, Action: ActionReject, Pattern: `fornax`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := p.Check("fornax"); got.Action != ActionMask {
+		t.Errorf("expected the earlier mask to win, got %+v", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(Config{Kind: KindRegex, Action: ActionMask, Pattern: "("}); err == nil {
+		t.Error("expected invalid regex to fail validation")
+	}
+	if err := Validate(Config{Kind: KindWordList, Action: "explode"}); err == nil {
+		t.Error("expected unknown action to fail validation")
+	}
+}
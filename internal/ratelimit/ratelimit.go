@@ -0,0 +1,168 @@
+// Package ratelimit limits how often each client may call an endpoint,
+// using token buckets. Every bucket holds up to Burst tokens and refills
+// continuously, so a client can make Burst requests at once and then one
+// more every Per/Burst.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Burst requests per Per.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Bucket is the stored state of one token bucket. The zero Bucket is full.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Decision is the outcome of spending a token.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a request would be allowed. It is zero
+	// when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// Take refills b for the time elapsed since it was last used and tries to
+// spend one token from it. It returns the bucket's new state.
+func Take(b Bucket, limit Limit, now time.Time) (Bucket, Decision) {
+	rate := limit.rate()
+	capacity := float64(limit.Burst)
+
+	tokens := capacity
+	if !b.UpdatedAt.IsZero() {
+		elapsed := math.Max(now.Sub(b.UpdatedAt).Seconds(), 0)
+		tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+
+	d := Decision{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	d.Remaining = int(math.Floor(tokens))
+	d.Reset = seconds((capacity - tokens) / rate)
+	return Bucket{Tokens: tokens, UpdatedAt: now}, d
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Store keeps buckets by key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// Principal is who a request is counted against.
+type Principal struct {
+	// Key identifies the client, e.g. "user:<id>" or "ip:<address>".
+	Key string
+	// Tier picks which of a rule's limits applies.
+	Tier string
+}
+
+// Rule is the limit for one route. Tiers overrides Default for principals
+// in the named tiers.
+type Rule struct {
+	Name    string
+	Default Limit
+	Tiers   map[string]Limit
+}
+
+func (r Rule) limitFor(tier string) Limit {
+	if l, ok := r.Tiers[tier]; ok {
+		return l
+	}
+	return r.Default
+}
+
+type Limiter struct {
+	store    Store
+	identify func(*http.Request) Principal
+}
+
+// New returns a Limiter that keeps buckets in store and uses identify to
+// decide who each request belongs to.
+func New(store Store, identify func(*http.Request) Principal) *Limiter {
+	return &Limiter{store: store, identify: identify}
+}
+
+// Wrap limits next according to rule. Every response carries the
+// RateLimit-* headers; rejected requests get 429 with Retry-After. If the
+// store fails the request is let through, since refusing all traffic is
+// worse than briefly not limiting it.
+func (l *Limiter) Wrap(rule Rule, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal := l.identify(r)
+		limit := rule.limitFor(principal.Tier)
+
+		d, err := l.store.Take(r.Context(), rule.Name+"|"+principal.Key, limit)
+		if err != nil {
+			log.Printf("ratelimit: %s: %v", rule.Name, err)
+			next(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, int(limit.Per.Seconds())))
+		h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+		if !d.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+			h.Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Too many requests"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ClientIP returns the address the request came from. With trustForwarded
+// set it believes the first X-Forwarded-For entry, which is only safe
+// behind a proxy that overwrites that header.
+func ClientIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			first, _, _ := strings.Cut(fwd, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
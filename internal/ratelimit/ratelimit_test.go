@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTakeRefillsOverTime(t *testing.T) {
+	limit := Limit{Burst: 2, Per: 10 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	b, d := Take(Bucket{}, limit, start)
+	if !d.Allowed || d.Remaining != 1 {
+		t.Fatalf("first request: got %+v", d)
+	}
+	b, d = Take(b, limit, start)
+	if !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("second request: got %+v", d)
+	}
+	b, d = Take(b, limit, start)
+	if d.Allowed {
+		t.Fatal("expected third request to be limited")
+	}
+	if d.RetryAfter != 5*time.Second {
+		t.Errorf("got retry after %v, want 5s", d.RetryAfter)
+	}
+
+	_, d = Take(b, limit, start.Add(5*time.Second))
+	if !d.Allowed {
+		t.Errorf("expected a token after 5s, got %+v", d)
+	}
+}
+
+func TestWrapSetsHeadersAndRejects(t *testing.T) {
+	limiter := New(NewMemoryStore(), func(r *http.Request) Principal {
+		return Principal{Key: "ip:" + ClientIP(r, false), Tier: r.Header.Get("Tier")}
+	})
+	rule := Rule{
+		Name:    "test",
+		Default: Limit{Burst: 1, Per: time.Minute},
+		Tiers:   map[string]Limit{"red": {Burst: 3, Per: time.Minute}},
+	}
+	handler := limiter.Wrap(rule, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	do := func(tier string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Tier", tier)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	if rec := do(""); rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: got %d %v", rec.Code, rec.Header())
+	}
+	rec := do("")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("got Retry-After %q, want 60", rec.Header().Get("Retry-After"))
+	}
+
+	// Tiers change the limit, not the bucket.
+	if rec := do("red"); rec.Header().Get("RateLimit-Limit") != "3" {
+		t.Errorf("expected red tier limit, got %v", rec.Header())
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+
+	if got := ClientIP(req, false); got != "10.0.0.1" {
+		t.Errorf("got %q, want the remote address", got)
+	}
+	if got := ClientIP(req, true); got != "203.0.113.7" {
+		t.Errorf("got %q, want the forwarded address", got)
+	}
+}
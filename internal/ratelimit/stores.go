@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in process. Limits only hold per instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	Bucket
+	per time.Duration
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]memoryBucket{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	bucket, d := Take(s.buckets[key].Bucket, limit, now)
+	s.buckets[key] = memoryBucket{Bucket: bucket, per: limit.Per}
+	return d, nil
+}
+
+// sweep forgets buckets that have had time to refill completely; they are
+// indistinguishable from new ones.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.UpdatedAt) >= b.per {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so every
+// instance shares them. Each Take locks its bucket's row for the length of
+// a short transaction.
+type PostgresStore struct {
+	db      *sql.DB
+	queries *database.Queries
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db, queries: database.New(db)}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	now := time.Now().UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer tx.Rollback()
+	q := s.queries.WithTx(tx)
+
+	// Creating the row first means concurrent first requests queue on the
+	// row lock below instead of each starting from a full bucket.
+	err = q.EnsureRateLimitBucket(ctx, database.EnsureRateLimitBucketParams{
+		Key:       key,
+		Tokens:    float64(limit.Burst),
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return Decision{}, err
+	}
+	row, err := q.LockRateLimitBucket(ctx, key)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	bucket, d := Take(Bucket{Tokens: row.Tokens, UpdatedAt: row.UpdatedAt}, limit, now)
+	err = q.UpdateRateLimitBucket(ctx, database.UpdateRateLimitBucketParams{
+		Key:       key,
+		Tokens:    bucket.Tokens,
+		UpdatedAt: bucket.UpdatedAt,
+	})
+	if err != nil {
+		return Decision{}, err
+	}
+	return d, tx.Commit()
+}
+
+// Prune deletes buckets untouched since before.
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	return s.queries.DeleteStaleRateLimitBuckets(ctx, before)
+}
@@ -0,0 +1,161 @@
+// Package spam scores new chirps on how likely they are to be spam. It
+// looks at near-duplicate bodies, link density, how fast the author is
+// posting and how new their account is; callers gather those signals and
+// decide what to do with the score.
+package spam
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	// ActionHold sends the chirp to the moderator review queue.
+	ActionHold   Action = "hold"
+	ActionReject Action = "reject"
+	// ActionThrottle rejects the chirp and stops the author posting for a
+	// while.
+	ActionThrottle Action = "throttle"
+)
+
+const (
+	shingleSize = 3
+	// minFingerprintWords keeps short chirps like "good morning" out of
+	// duplicate detection; they collide too often to mean anything.
+	minFingerprintWords = 4
+	// NearDuplicateDistance is the largest number of differing simhash bits
+	// at which two bodies count as the same text.
+	NearDuplicateDistance = 3
+)
+
+// Fingerprint returns the simhash of text's word shingles. ok is false for
+// text too short to fingerprint meaningfully.
+func Fingerprint(text string) (hash uint64, ok bool) {
+	words := Words(text)
+	if len(words) < minFingerprintWords {
+		return 0, false
+	}
+
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash, true
+}
+
+// Distance is the number of bits in which two fingerprints differ.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Words splits text into lowercase words of letters and digits.
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Signals are the facts a chirp is scored on.
+type Signals struct {
+	// DuplicatesSameAuthor and DuplicatesOtherAuthors count recent
+	// near-duplicates of the body.
+	DuplicatesSameAuthor   int `json:"duplicates_same_author"`
+	DuplicatesOtherAuthors int `json:"duplicates_other_authors"`
+	Links                  int `json:"links"`
+	Words                  int `json:"words"`
+	// RecentChirps is how many chirps the author posted in the velocity
+	// window before this one.
+	RecentChirps int           `json:"recent_chirps"`
+	AccountAge   time.Duration `json:"account_age"`
+}
+
+// Thresholds map a score to an action. A score at or above a threshold
+// gets that action; the strictest one reached wins.
+type Thresholds struct {
+	Hold     float64
+	Reject   float64
+	Throttle float64
+}
+
+var DefaultThresholds = Thresholds{Hold: 0.5, Reject: 0.8, Throttle: 1.2}
+
+// Result is a scored chirp.
+type Result struct {
+	Score   float64
+	Action  Action
+	Reasons []string
+}
+
+const (
+	// velocityAllowance is how many chirps an author may post within the
+	// velocity window before it counts against them.
+	velocityAllowance = 5
+	newAccountAge     = 24 * time.Hour
+)
+
+// Score weighs the signals and picks an action.
+func Score(s Signals, t Thresholds) Result {
+	r := Result{Reasons: []string{}}
+	add := func(score float64, reason string) {
+		r.Score += score
+		r.Reasons = append(r.Reasons, reason)
+	}
+
+	if s.DuplicatesSameAuthor > 0 {
+		add(math.Min(0.35*float64(s.DuplicatesSameAuthor), 0.7),
+			fmt.Sprintf("repeats %d of the author's recent chirps", s.DuplicatesSameAuthor))
+	}
+	if s.DuplicatesOtherAuthors > 0 {
+		add(math.Min(0.2*float64(s.DuplicatesOtherAuthors), 0.6),
+			fmt.Sprintf("matches %d recent chirps by other accounts", s.DuplicatesOtherAuthors))
+	}
+	if s.Links > 0 && s.Words > 0 {
+		if density := float64(s.Links) / float64(s.Words); density > 0.25 {
+			add(0.3, fmt.Sprintf("%d links in %d words", s.Links, s.Words))
+		}
+	}
+	if s.Links > 2 {
+		add(0.2, fmt.Sprintf("%d links", s.Links))
+	}
+	if extra := s.RecentChirps - velocityAllowance; extra > 0 {
+		add(math.Min(0.1*float64(extra), 0.5), fmt.Sprintf("%d chirps in quick succession", s.RecentChirps+1))
+	}
+	// A new account only makes other signals look worse; on its own it is
+	// no reason to suspect anyone.
+	if s.AccountAge < newAccountAge && r.Score > 0 {
+		add(0.15, "account is less than a day old")
+	}
+
+	r.Action = ActionAllow
+	switch {
+	case r.Score >= t.Throttle:
+		r.Action = ActionThrottle
+	case r.Score >= t.Reject:
+		r.Action = ActionReject
+	case r.Score >= t.Hold:
+		r.Action = ActionHold
+	}
+	return r
+}
@@ -0,0 +1,52 @@
+package spam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFingerprintFindsNearDuplicates(t *testing.T) {
+	a, ok := Fingerprint("Click here to win a free cruise to the Bahamas, limited offer for today only!")
+	if !ok {
+		t.Fatal("expected a fingerprint")
+	}
+	b, _ := Fingerprint("click here to win a FREE cruise to the bahamas - limited offer for today only")
+	c, _ := Fingerprint("Had a lovely walk along the river this morning, the herons were out in force")
+
+	if d := Distance(a, b); d > NearDuplicateDistance {
+		t.Errorf("expected near-duplicates, distance %d", d)
+	}
+	if d := Distance(a, c); d <= NearDuplicateDistance {
+		t.Errorf("expected different texts to differ, distance %d", d)
+	}
+}
+
+func TestShortTextHasNoFingerprint(t *testing.T) {
+	if _, ok := Fingerprint("good morning"); ok {
+		t.Error("expected short text to be skipped")
+	}
+}
+
+func TestScore(t *testing.T) {
+	established := 30 * 24 * time.Hour
+
+	clean := Score(Signals{Words: 12, AccountAge: time.Hour}, DefaultThresholds)
+	if clean.Action != ActionAllow || clean.Score != 0 {
+		t.Errorf("expected a clean chirp from a new account to pass, got %+v", clean)
+	}
+
+	linky := Score(Signals{Words: 6, Links: 3, AccountAge: established}, DefaultThresholds)
+	if linky.Action != ActionHold {
+		t.Errorf("expected link spam to be held, got %+v", linky)
+	}
+
+	flood := Score(Signals{
+		Words:                12,
+		DuplicatesSameAuthor: 3,
+		RecentChirps:         12,
+		AccountAge:           time.Minute,
+	}, DefaultThresholds)
+	if flood.Action != ActionThrottle {
+		t.Errorf("expected a new account flooding duplicates to be throttled, got %+v", flood)
+	}
+}
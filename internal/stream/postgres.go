@@ -0,0 +1,80 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+const notifyChannel = "chirpy_stream"
+
+// PostgresBroker publishes events with NOTIFY and feeds everything it
+// hears on the channel into a local hub, including its own events, so
+// each event reaches every process's hub exactly once.
+type PostgresBroker struct {
+	db  *database.Queries
+	hub *Hub
+}
+
+func NewPostgresBroker(db *database.Queries, hub *Hub) *PostgresBroker {
+	return &PostgresBroker{db: db, hub: hub}
+}
+
+// Publish takes the event ID from a shared sequence. NOTIFY payloads are
+// limited to 8000 bytes, so events must stay small.
+func (b *PostgresBroker) Publish(ctx context.Context, e Event) error {
+	id, err := b.db.NextStreamEventID(ctx)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.db.NotifyStreamEvent(ctx, database.NotifyStreamEventParams{
+		Channel: notifyChannel,
+		Payload: string(payload),
+	})
+}
+
+// Listen relays notifications into the hub until ctx is cancelled. Events
+// sent while the connection is down are lost; clients that resume across
+// the gap are told their stream is incomplete.
+func (b *PostgresBroker) Listen(ctx context.Context, dbURL string) error {
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("stream: listener: %v", err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(notifyChannel); err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established.
+			if n == nil {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Printf("stream: bad notification: %v", err)
+				continue
+			}
+			b.hub.Publish(ctx, e)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package stream fans events out to long-lived client connections. A Hub
+// delivers events to subscribers in this process and keeps a short backlog
+// so reconnecting clients can resume where they left off. With several
+// server processes, PostgresBroker relays events between them over
+// LISTEN/NOTIFY so every hub sees every event.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultBacklog = 1000
+	// subscriberBuffer is how many events a subscriber can fall behind
+	// before it is dropped.
+	subscriberBuffer = 64
+)
+
+type Event struct {
+	// ID increases with every event. Clients send the last ID they saw to
+	// resume.
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+	// Author is the user the event is about, used for filtering.
+	Author uuid.UUID `json:"author"`
+	// Recipient is set on events meant for a single user, such as
+	// notifications.
+	Recipient uuid.UUID `json:"recipient"`
+	// Chirps lists the chirps the event concerns: the chirp itself and the
+	// one it quotes or rechirps.
+	Chirps []uuid.UUID     `json:"chirps,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Broker publishes events to every subscriber, wherever they're connected.
+type Broker interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// Filter decides whether a subscriber receives an event.
+type Filter func(Event) bool
+
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter Filter
+	hub    *Hub
+	once   sync.Once
+}
+
+// Close unsubscribes. It is safe to call more than once, and after the hub
+// dropped the subscription.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+type Hub struct {
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	backlog []Event
+	size    int
+	lastID  int64
+}
+
+func NewHub(backlog int) *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}, size: backlog}
+}
+
+// Publish assigns the event the next ID, unless it already carries one from
+// another process, and delivers it. A subscriber whose buffer is full is
+// dropped: its channel is closed so the client reconnects and resumes from
+// the backlog instead of silently missing events.
+func (h *Hub) Publish(_ context.Context, e Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e.ID == 0 {
+		e.ID = h.lastID + 1
+	}
+	if e.ID > h.lastID {
+		h.lastID = e.ID
+	}
+	h.backlog = append(h.backlog, e)
+	if len(h.backlog) > h.size {
+		h.backlog = h.backlog[len(h.backlog)-h.size:]
+	}
+
+	for sub := range h.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			h.drop(sub)
+		}
+	}
+	return nil
+}
+
+// Subscribe starts delivering events that pass filter. When lastID is
+// non-zero, the events after it that are still in the backlog are returned
+// for the caller to send first; complete is false if some were already
+// discarded, so the client should refetch instead of trusting the stream.
+func (h *Hub) Subscribe(lastID int64, filter Filter) (sub *Subscription, missed []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: c, c: c, filter: filter, hub: h}
+	h.subs[sub] = struct{}{}
+
+	complete = true
+	if lastID == 0 {
+		return sub, nil, complete
+	}
+	if lastID > h.lastID {
+		// The ID came from a previous run of the server, or another process
+		// this one hasn't caught up with.
+		return sub, nil, false
+	}
+	if len(h.backlog) > 0 && h.backlog[0].ID > lastID+1 {
+		complete = false
+	}
+	for _, e := range h.backlog {
+		if e.ID > lastID && (filter == nil || filter(e)) {
+			missed = append(missed, e)
+		}
+	}
+	return sub, missed, complete
+}
+
+// Subscribers returns how many subscriptions are open.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// CloseAll drops every subscription, for shutdown.
+func (h *Hub) CloseAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		h.drop(sub)
+	}
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+// drop must be called with h.mu held.
+func (h *Hub) drop(sub *Subscription) {
+	delete(h.subs, sub)
+	sub.once.Do(func() { close(sub.c) })
+}
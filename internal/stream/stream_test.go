@@ -0,0 +1,132 @@
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func publish(t *testing.T, h *Hub, author uuid.UUID) {
+	t.Helper()
+	if err := h.Publish(context.Background(), Event{Type: "chirp.created", Author: author}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case e, ok := <-sub.C:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	default:
+		t.Fatal("expected an event")
+	}
+	return Event{}
+}
+
+func TestPublishFiltersAndOrders(t *testing.T) {
+	h := NewHub(DefaultBacklog)
+	alice, bob := uuid.New(), uuid.New()
+
+	all, _, _ := h.Subscribe(0, nil)
+	onlyBob, _, _ := h.Subscribe(0, func(e Event) bool { return e.Author == bob })
+	defer all.Close()
+	defer onlyBob.Close()
+
+	publish(t, h, alice)
+	publish(t, h, bob)
+
+	if e := receive(t, all); e.ID != 1 || e.Author != alice {
+		t.Errorf("unexpected first event %+v", e)
+	}
+	if e := receive(t, all); e.ID != 2 {
+		t.Errorf("unexpected second event %+v", e)
+	}
+	if e := receive(t, onlyBob); e.Author != bob {
+		t.Errorf("filter let through %+v", e)
+	}
+	select {
+	case e := <-onlyBob.C:
+		t.Errorf("unexpected extra event %+v", e)
+	default:
+	}
+}
+
+func TestSubscribeResumesFromBacklog(t *testing.T) {
+	h := NewHub(3)
+	author := uuid.New()
+	for i := 0; i < 5; i++ {
+		publish(t, h, author)
+	}
+
+	sub, missed, complete := h.Subscribe(3, nil)
+	defer sub.Close()
+	if !complete || len(missed) != 2 || missed[0].ID != 4 || missed[1].ID != 5 {
+		t.Errorf("expected events 4 and 5, got %+v (complete %v)", missed, complete)
+	}
+
+	sub2, missed, complete := h.Subscribe(1, nil)
+	defer sub2.Close()
+	if complete {
+		t.Error("expected a gap: event 2 fell out of the backlog")
+	}
+	if len(missed) != 3 {
+		t.Errorf("expected what's left of the backlog, got %+v", missed)
+	}
+
+	sub3, _, complete := h.Subscribe(99, nil)
+	defer sub3.Close()
+	if complete {
+		t.Error("expected an unknown ID to be reported as a gap")
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	h := NewHub(DefaultBacklog)
+	sub, _, _ := h.Subscribe(0, nil)
+	for i := 0; i < subscriberBuffer+1; i++ {
+		publish(t, h, uuid.New())
+	}
+
+	if h.Subscribers() != 0 {
+		t.Fatal("expected the slow subscriber to be removed")
+	}
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected the buffered events before close, got %d", n)
+	}
+	sub.Close()
+}
+
+func TestEventsFromOtherProcessesKeepTheirIDs(t *testing.T) {
+	h := NewHub(DefaultBacklog)
+	sub, _, _ := h.Subscribe(0, nil)
+	defer sub.Close()
+
+	h.Publish(context.Background(), Event{ID: 41, Type: "chirp.created"})
+	publish(t, h, uuid.New())
+
+	if e := receive(t, sub); e.ID != 41 {
+		t.Errorf("expected ID 41, got %d", e.ID)
+	}
+	if e := receive(t, sub); e.ID != 42 {
+		t.Errorf("expected local IDs to continue from 41, got %d", e.ID)
+	}
+}
+
+func TestCloseAll(t *testing.T) {
+	h := NewHub(DefaultBacklog)
+	sub, _, _ := h.Subscribe(0, nil)
+	h.CloseAll()
+	if _, ok := <-sub.C; ok {
+		t.Error("expected the channel to be closed")
+	}
+	sub.Close()
+}
@@ -0,0 +1,80 @@
+// Package trends scores hashtags by how much faster they are being used now
+// than they were over a longer baseline period.
+package trends
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Window is a trending period: tags are compared over Recent against their
+// average rate over the Baseline that precedes it.
+type Window struct {
+	Name     string
+	Recent   time.Duration
+	Baseline time.Duration
+}
+
+var (
+	Hour = Window{Name: "hour", Recent: time.Hour, Baseline: 24 * time.Hour}
+	Day  = Window{Name: "day", Recent: 24 * time.Hour, Baseline: 7 * 24 * time.Hour}
+)
+
+// Count is how often a tag was used in the recent part of a window and over
+// the whole window (recent plus baseline).
+type Count struct {
+	Tag    string
+	Recent int64
+	Total  int64
+}
+
+type Trend struct {
+	Tag         string  `json:"tag"`
+	Score       float64 `json:"score"`
+	RecentCount int64   `json:"recent_count"`
+	// BaselineRate is the average number of uses per Recent-sized period
+	// during the baseline.
+	BaselineRate float64 `json:"baseline_rate"`
+}
+
+// Score ranks tags by velocity: how far the recent count sits above what
+// the baseline rate predicts, scaled by the square root of that prediction
+// so that a jump from 0 to 20 outranks a jump from 1000 to 1020. Tags used
+// fewer than minCount times recently, or not above their baseline, are left
+// out. At most limit trends are returned, best first.
+func Score(counts []Count, w Window, minCount int64, limit int) []Trend {
+	periods := float64(w.Baseline-w.Recent) / float64(w.Recent)
+	if periods <= 0 {
+		periods = 1
+	}
+
+	scored := []Trend{}
+	for _, c := range counts {
+		if c.Recent < minCount {
+			continue
+		}
+		baselineRate := float64(c.Total-c.Recent) / periods
+		score := (float64(c.Recent) - baselineRate) / math.Sqrt(baselineRate+1)
+		if score <= 0 {
+			continue
+		}
+		scored = append(scored, Trend{
+			Tag:          c.Tag,
+			Score:        score,
+			RecentCount:  c.Recent,
+			BaselineRate: baselineRate,
+		})
+	}
+
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].Score == scored[j].Score {
+			return scored[i].Tag < scored[j].Tag
+		}
+		return scored[i].Score > scored[j].Score
+	})
+	if len(scored) > limit {
+		scored = scored[:limit]
+	}
+	return scored
+}
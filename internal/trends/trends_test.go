@@ -0,0 +1,37 @@
+package trends
+
+import "testing"
+
+func TestScoreFavoursVelocityOverVolume(t *testing.T) {
+	counts := []Count{
+		// Always busy: 1000 an hour for the last day.
+		{Tag: "news", Recent: 1000, Total: 24000},
+		// Quiet until the last hour.
+		{Tag: "eclipse", Recent: 40, Total: 42},
+		// Slowing down.
+		{Tag: "yesterday", Recent: 5, Total: 500},
+		// Too few uses to count.
+		{Tag: "rare", Recent: 2, Total: 2},
+	}
+
+	got := Score(counts, Hour, 3, 10)
+	if len(got) != 1 {
+		t.Fatalf("expected only one trend, got %+v", got)
+	}
+	if got[0].Tag != "eclipse" {
+		t.Errorf("expected eclipse to trend, got %q", got[0].Tag)
+	}
+}
+
+func TestScoreRespectsLimit(t *testing.T) {
+	counts := []Count{
+		{Tag: "a", Recent: 10, Total: 10},
+		{Tag: "b", Recent: 20, Total: 20},
+		{Tag: "c", Recent: 30, Total: 30},
+	}
+
+	got := Score(counts, Day, 1, 2)
+	if len(got) != 2 || got[0].Tag != "c" || got[1].Tag != "b" {
+		t.Errorf("unexpected trends %+v", got)
+	}
+}
@@ -0,0 +1,243 @@
+// Package webhooks signs and sends outgoing webhook deliveries. Each request
+// carries a Chirpy-Signature header of the form "t=<unix time>,v1=<hex>",
+// where the hex is an HMAC-SHA256 of "<unix time>.<body>" keyed with the
+// endpoint's secret. Receivers check it with Verify.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"workspace/github.com/Benjysparks/chirpy/internal/linkpreview"
+)
+
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+
+	DefaultTimeout = 10 * time.Second
+	// DefaultTolerance is how old a signature Verify accepts, to limit
+	// replays.
+	DefaultTolerance = 5 * time.Minute
+	// MaxAttempts is how many times a delivery is tried before it is given
+	// up as dead: once straight away, then after each Backoff step.
+	MaxAttempts = len(backoffSchedule) + 1
+
+	// maxResponseBytes is how much of the receiver's response is kept for
+	// the delivery log.
+	maxResponseBytes = 4 << 10
+	secretPrefix     = "whsec_"
+)
+
+var backoffSchedule = [...]time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+	12 * time.Hour,
+}
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidURL       = errors.New("invalid webhook URL")
+	// ErrBadStatus means the receiver answered with something other than
+	// 2xx. Redirects aren't followed, so they count too.
+	ErrBadStatus = errors.New("receiver returned an error status")
+)
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseHeader splits a signature header into its timestamp and signatures.
+func parseHeader(header string) (ts string, sigs []string) {
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+	return ts, sigs
+}
+
+// SignedAt returns the time a signature header was made. It doesn't check
+// the signature; call Verify first.
+func SignedAt(header string) (time.Time, error) {
+	ts, _ := parseHeader(header)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// Verify checks a signature header against body. Signatures made more than
+// tolerance before or after now are rejected.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, sigs := parseHeader(header)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	want := []byte(signature(secret, ts, body))
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Backoff is the delay before the next attempt of a delivery that has
+// failed attempts times.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > len(backoffSchedule) {
+		attempts = len(backoffSchedule)
+	}
+	return backoffSchedule[attempts-1]
+}
+
+// ValidURL checks an endpoint URL when it is registered. Private addresses
+// are refused again when connecting, since a hostname can resolve anywhere.
+func ValidURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !linkpreview.PublicIP(ip) {
+		return fmt.Errorf("%w: private address", ErrInvalidURL)
+	}
+	return nil
+}
+
+type Delivery struct {
+	ID     string
+	Event  string
+	URL    string
+	Secret string
+	Body   []byte
+}
+
+// Result describes one attempt, for the delivery log. StatusCode is zero
+// when no response arrived.
+type Result struct {
+	StatusCode int
+	Body       string
+	Duration   time.Duration
+}
+
+type Sender struct {
+	HTTP *http.Client
+}
+
+// NewSender returns a Sender that only connects to public addresses and
+// doesn't follow redirects, since endpoint URLs come from users.
+func NewSender(timeout time.Duration) *Sender {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !linkpreview.PublicIP(ip) {
+				return fmt.Errorf("%w: %s", linkpreview.ErrBlockedAddress, host)
+			}
+			return nil
+		},
+	}
+	return &Sender{
+		HTTP: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   timeout,
+				ResponseHeaderTimeout: timeout,
+				MaxIdleConns:          20,
+				IdleConnTimeout:       30 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send makes one delivery attempt. The Result is filled in as far as the
+// attempt got, even when it fails.
+func (s *Sender) Send(ctx context.Context, d Delivery) (Result, error) {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(SignatureHeader, Sign(d.Secret, start, d.Body))
+
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(start)}, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+
+	result := Result{StatusCode: resp.StatusCode, Body: string(body), Duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("%w: %d", ErrBadStatus, resp.StatusCode)
+	}
+	return result, nil
+}
@@ -0,0 +1,130 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"workspace/github.com/Benjysparks/chirpy/internal/linkpreview"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"chirp.created"}`)
+	header := Sign("whsec_test", now, body)
+
+	if err := Verify("whsec_test", header, body, now.Add(time.Minute), DefaultTolerance); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := Verify("whsec_other", header, body, now, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected the wrong secret to fail, got %v", err)
+	}
+	if err := Verify("whsec_test", header, []byte(`{"event":"chirp.deleted"}`), now, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a changed body to fail, got %v", err)
+	}
+	if err := Verify("whsec_test", header, body, now.Add(time.Hour), DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected an old signature to fail, got %v", err)
+	}
+	if err := Verify("whsec_test", "garbage", body, now, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a malformed header to fail, got %v", err)
+	}
+
+	if at, err := SignedAt(header); err != nil || !at.Equal(now) {
+		t.Errorf("SignedAt = %v, %v; want %v", at, err, now)
+	}
+	if _, err := SignedAt("garbage"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a malformed header to have no time, got %v", err)
+	}
+}
+
+func TestSendSignsTheDelivery(t *testing.T) {
+	type received struct {
+		event, delivery string
+		err             error
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{
+			event:    r.Header.Get(EventHeader),
+			delivery: r.Header.Get(DeliveryHeader),
+			err:      Verify("whsec_test", r.Header.Get(SignatureHeader), body, time.Now(), DefaultTolerance),
+		}
+		w.Write([]byte("thanks"))
+	}))
+	defer srv.Close()
+
+	sender := &Sender{HTTP: srv.Client()}
+	result, err := sender.Send(context.Background(), Delivery{
+		ID:     "d1",
+		Event:  "user.followed",
+		URL:    srv.URL,
+		Secret: "whsec_test",
+		Body:   []byte(`{}`),
+	})
+	if err != nil || result.StatusCode != http.StatusOK || result.Body != "thanks" {
+		t.Fatalf("unexpected result %+v %v", result, err)
+	}
+	r := <-got
+	if r.err != nil || r.event != "user.followed" || r.delivery != "d1" {
+		t.Errorf("receiver saw %+v", r)
+	}
+}
+
+func TestSendReportsBadStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, strings.Repeat("x", 2*maxResponseBytes), http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	sender := &Sender{HTTP: srv.Client()}
+	result, err := sender.Send(context.Background(), Delivery{URL: srv.URL, Body: []byte(`{}`)})
+	if !errors.Is(err, ErrBadStatus) || result.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a bad status error, got %+v %v", result, err)
+	}
+	if len(result.Body) != maxResponseBytes {
+		t.Errorf("expected the response to be truncated, got %d bytes", len(result.Body))
+	}
+}
+
+func TestNewSenderRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback receiver")
+	}))
+	defer srv.Close()
+
+	_, err := NewSender(time.Second).Send(context.Background(), Delivery{URL: srv.URL, Body: []byte(`{}`)})
+	if !errors.Is(err, linkpreview.ErrBlockedAddress) {
+		t.Errorf("expected the address to be blocked, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if Backoff(1) != time.Minute || Backoff(3) != 30*time.Minute {
+		t.Error("unexpected schedule")
+	}
+	if Backoff(100) != 12*time.Hour {
+		t.Errorf("expected the delay to be capped, got %v", Backoff(100))
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://example.com/hooks":    true,
+		"http://example.com:8080/hook": true,
+		"ftp://example.com":            false,
+		"https://user:pw@example.com":  false,
+		"https://10.0.0.1/hook":        false,
+		"https://[::1]/hook":           false,
+		"not a url":                    false,
+	} {
+		if err := ValidURL(raw); (err == nil) != ok {
+			t.Errorf("ValidURL(%q) = %v", raw, err)
+		}
+	}
+}
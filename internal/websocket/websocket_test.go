@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func pipe() (server, client *Conn) {
+	a, b := net.Pipe()
+	return newConn(a, bufio.NewReader(a), false), newConn(b, bufio.NewReader(b), true)
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455 section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestUpgradeAndEcho(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close(CloseNormal, "")
+		op, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(op, data)
+	}))
+	defer srv.Close()
+
+	netConn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netConn.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(netConn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	client := newConn(netConn, br, true)
+	if err := client.WriteMessage(OpText, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	op, data, err := client.ReadMessage()
+	if err != nil || op != OpText || string(data) != "hello" {
+		t.Fatalf("expected the echo, got %d %q %v", op, data, err)
+	}
+	_, _, err = client.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseNormal {
+		t.Errorf("expected a normal close, got %v", err)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	rec := httptest.NewRecorder()
+	_, err := Upgrade(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(err, ErrBadHandshake) || rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a 400 bad handshake, got %d %v", rec.Code, err)
+	}
+}
+
+func TestFragmentsAndPings(t *testing.T) {
+	server, client := pipe()
+	go func() {
+		client.writeFrame(false, OpText, []byte("hel"))
+		client.writeFrame(true, opPing, []byte("are you there"))
+		client.writeFrame(true, opContinuation, []byte("lo"))
+	}()
+
+	// The pong is written while the message is being read, so the client
+	// has to be reading too.
+	pong := make(chan string, 1)
+	go func() {
+		_, payload, _ := readRawFrame(client)
+		pong <- string(payload)
+	}()
+
+	op, data, err := server.ReadMessage()
+	if err != nil || op != OpText || string(data) != "hello" {
+		t.Fatalf("expected the reassembled message, got %d %q %v", op, data, err)
+	}
+	if got := <-pong; got != "are you there" {
+		t.Errorf("expected the ping payload echoed, got %q", got)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		send  func(client *Conn)
+		limit int64
+		code  int
+	}{
+		{
+			name: "unmasked frame",
+			send: func(client *Conn) { client.conn.Write([]byte{0x81, 0x02, 'h', 'i'}) },
+			code: CloseProtocolError,
+		},
+		{
+			name:  "message too big",
+			send:  func(client *Conn) { client.WriteMessage(OpBinary, make([]byte, 32)) },
+			limit: 16,
+			code:  CloseTooBig,
+		},
+		{
+			name: "too big across fragments",
+			send: func(client *Conn) {
+				client.writeFrame(false, OpBinary, make([]byte, 10))
+				client.writeFrame(true, opContinuation, make([]byte, 10))
+			},
+			limit: 16,
+			code:  CloseTooBig,
+		},
+		{
+			name: "invalid UTF-8",
+			send: func(client *Conn) { client.WriteMessage(OpText, []byte{0xff, 0xfe}) },
+			code: CloseInvalidData,
+		},
+		{
+			name: "stray continuation",
+			send: func(client *Conn) { client.writeFrame(true, opContinuation, []byte("x")) },
+			code: CloseProtocolError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := pipe()
+			if tt.limit > 0 {
+				server.SetReadLimit(tt.limit)
+			}
+			go tt.send(client)
+			sent := make(chan int, 1)
+			go func() {
+				_, payload, _ := readRawFrame(client)
+				code := 0
+				if len(payload) >= 2 {
+					code = int(payload[0])<<8 | int(payload[1])
+				}
+				sent <- code
+			}()
+
+			_, _, err := server.ReadMessage()
+			var closeErr *CloseError
+			if !errors.As(err, &closeErr) || closeErr.Code != tt.code {
+				t.Fatalf("expected close code %d, got %v", tt.code, err)
+			}
+			if got := <-sent; got != tt.code {
+				t.Errorf("expected a close frame with %d, got %d", tt.code, got)
+			}
+		})
+	}
+}
+
+// readRawFrame reads one frame without ReadMessage's handling of control
+// frames.
+func readRawFrame(c *Conn) (op int, payload []byte, err error) {
+	_, op, payload, err = c.readFrame()
+	return op, payload, err
+}
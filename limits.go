@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/entitlements"
+)
+
+// chirpQuotaWindow is how far back the daily chirp limit looks.
+const chirpQuotaWindow = 24 * time.Hour
+
+// loadLimitPolicy starts from the built-in plan limits and applies any
+// LIMITS_<PLAN>_<LIMIT> overrides, e.g. LIMITS_RED_MAX_CHIRP_LENGTH=1000.
+// A daily limit of 0 removes the cap.
+func loadLimitPolicy() entitlements.Policy {
+	policy := entitlements.DefaultPolicy()
+	for plan, e := range policy.Plans {
+		prefix := "LIMITS_" + strings.ToUpper(plan) + "_"
+		e.MaxChirpLength = envInt(prefix+"MAX_CHIRP_LENGTH", e.MaxChirpLength)
+		e.MaxChirpsPerDay = envInt(prefix+"MAX_CHIRPS_PER_DAY", e.MaxChirpsPerDay)
+		e.MaxAttachments = envInt(prefix+"MAX_ATTACHMENTS", e.MaxAttachments)
+		policy.Plans[plan] = e
+	}
+	return policy
+}
+
+type planLimits struct {
+	MaxChirpLength  int `json:"max_chirp_length"`
+	MaxChirpsPerDay int `json:"max_chirps_per_day"`
+	MaxAttachments  int `json:"max_attachments"`
+}
+
+func limitsOf(e entitlements.Entitlements) planLimits {
+	return planLimits{
+		MaxChirpLength:  e.MaxChirpLength,
+		MaxChirpsPerDay: e.MaxChirpsPerDay,
+		MaxAttachments:  e.MaxAttachments,
+	}
+}
+
+// chirpsToday counts the chirps a user has posted or scheduled within the
+// quota window. Rechirps and unpublished drafts don't count.
+func (cfg *apiConfig) chirpsToday(ctx context.Context, userID uuid.UUID) (int, error) {
+	n, err := cfg.db.CountPostedChirpsSince(ctx, database.CountPostedChirpsSinceParams{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC().Add(-chirpQuotaWindow),
+	})
+	return int(n), err
+}
+
+// checkChirpLength responds and returns false if body is longer than the
+// user's plan allows.
+func (cfg *apiConfig) checkChirpLength(w http.ResponseWriter, r *http.Request, userID uuid.UUID, body string) bool {
+	limits, err := cfg.userEntitlements(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load account limits", err)
+		return false
+	}
+	if err := limits.CheckChirpLength(body); err != nil {
+		respondLimitExceeded(w, err)
+		return false
+	}
+	return true
+}
+
+// respondLimitExceeded reports which of the user's plan limits a request
+// went over, so clients can show it or suggest upgrading.
+func respondLimitExceeded(w http.ResponseWriter, err error) {
+	type response struct {
+		Error string `json:"error"`
+		Limit string `json:"limit"`
+		Max   int    `json:"max"`
+		Plan  string `json:"plan"`
+	}
+
+	var limitErr *entitlements.LimitError
+	if !errors.As(err, &limitErr) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check account limits", err)
+		return
+	}
+
+	code := http.StatusBadRequest
+	var msg string
+	switch limitErr.Limit {
+	case entitlements.LimitChirpLength:
+		msg = fmt.Sprintf("Chirp is too long: the limit is %d characters", limitErr.Max)
+	case entitlements.LimitChirpsPerDay:
+		code = http.StatusTooManyRequests
+		msg = fmt.Sprintf("You can post %d chirps a day", limitErr.Max)
+	case entitlements.LimitAttachments:
+		msg = fmt.Sprintf("A chirp can have at most %d attachments", limitErr.Max)
+	default:
+		msg = "Limit exceeded: " + limitErr.Limit
+	}
+	respondWithJSON(w, code, response{
+		Error: msg,
+		Limit: limitErr.Limit,
+		Max:   limitErr.Max,
+		Plan:  limitErr.Plan,
+	})
+}
+
+// handlerLimits lists every plan's limits. Signed-in users also get their
+// own plan and how many chirps they have left today.
+func (cfg *apiConfig) handlerLimits(w http.ResponseWriter, r *http.Request) {
+	type usage struct {
+		ChirpsToday     int  `json:"chirps_today"`
+		ChirpsRemaining *int `json:"chirps_remaining_today,omitempty"`
+	}
+	type response struct {
+		Plan   string                `json:"plan,omitempty"`
+		Limits *planLimits           `json:"limits,omitempty"`
+		Usage  *usage                `json:"usage,omitempty"`
+		Plans  map[string]planLimits `json:"plans"`
+	}
+
+	userID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	resp := response{Plans: map[string]planLimits{}}
+	for plan, e := range cfg.limitPolicy.Plans {
+		resp.Plans[plan] = limitsOf(e)
+	}
+
+	if userID != uuid.Nil {
+		granted, err := cfg.userEntitlements(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load account limits", err)
+			return
+		}
+		posted, err := cfg.chirpsToday(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load account limits", err)
+			return
+		}
+
+		limits := limitsOf(granted)
+		resp.Plan = granted.Plan
+		resp.Limits = &limits
+		resp.Usage = &usage{ChirpsToday: posted}
+		if granted.MaxChirpsPerDay > 0 {
+			remaining := max(granted.MaxChirpsPerDay-posted, 0)
+			resp.Usage.ChirpsRemaining = &remaining
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/moderation"
+)
+
+const (
+	jobFetchLinkPreviews = "chirps.fetch_link_previews"
+	// maxPreviewsPerChirp caps how many links in one chirp get a card.
+	maxPreviewsPerChirp = 3
+	linkPreviewTTL      = 24 * time.Hour
+	// failedPreviewTTL is how long to wait before trying a broken link
+	// again.
+	failedPreviewTTL = time.Hour
+
+	linkPreviewOK     = "ok"
+	linkPreviewFailed = "failed"
+)
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+type linkPreviewPayload struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// chirpLinks returns the distinct http(s) links in body, normalised so the
+// same page is cached once, up to maxPreviewsPerChirp.
+func chirpLinks(body string) []string {
+	links := []string{}
+	seen := map[string]bool{}
+	for _, link := range moderation.Links(body) {
+		link = strings.TrimRight(link, ".,;:!?)]}'")
+		if strings.HasPrefix(strings.ToLower(link), "www.") {
+			link = "https://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		u.Fragment = ""
+		link = u.String()
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+		if len(links) == maxPreviewsPerChirp {
+			break
+		}
+	}
+	return links
+}
+
+// jobFetchLinkPreviews attaches preview cards to a chirp's links, fetching
+// pages that aren't cached or whose cache entry has expired. A page that
+// can't be fetched is cached as failed rather than retried, so one bad link
+// doesn't hold up the rest.
+func (cfg *apiConfig) jobFetchLinkPreviews(ctx context.Context, raw json.RawMessage) error {
+	var payload linkPreviewPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return err
+	}
+
+	chirp, err := cfg.db.GetChirpsByID(ctx, payload.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, link := range chirpLinks(chirp.Body) {
+		if err := cfg.refreshLinkPreview(ctx, link); err != nil {
+			return err
+		}
+		err := cfg.db.AddChirpLink(ctx, database.AddChirpLinkParams{
+			ChirpID:  chirp.ID,
+			Url:      link,
+			Position: int32(i),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) refreshLinkPreview(ctx context.Context, link string) error {
+	cached, err := cfg.db.GetLinkPreview(ctx, link)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		ttl := linkPreviewTTL
+		if cached.Status == linkPreviewFailed {
+			ttl = failedPreviewTTL
+		}
+		if time.Since(cached.FetchedAt) < ttl {
+			return nil
+		}
+	}
+
+	params := database.SaveLinkPreviewParams{Url: link, Status: linkPreviewOK}
+	preview, err := cfg.previews.Fetch(ctx, link)
+	if err != nil {
+		log.Printf("Couldn't fetch preview for %s: %v", link, err)
+		params.Status = linkPreviewFailed
+	} else {
+		params.Title = preview.Title
+		params.Description = preview.Description
+		params.ImageUrl = preview.ImageURL
+		params.SiteName = preview.SiteName
+	}
+	if params.Status == linkPreviewOK && params.Title == "" && params.Description == "" {
+		// Nothing worth showing as a card.
+		params.Status = linkPreviewFailed
+	}
+	return cfg.db.SaveLinkPreview(ctx, params)
+}
+
+// loadLinkPreviews fetches the preview cards of every chirp in chirpIDs.
+func (cfg *apiConfig) loadLinkPreviews(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]LinkPreview, error) {
+	byChirp := map[uuid.UUID][]LinkPreview{}
+	if len(chirpIDs) == 0 {
+		return byChirp, nil
+	}
+
+	rows, err := cfg.db.GetLinkPreviewsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		byChirp[row.ChirpID] = append(byChirp[row.ChirpID], LinkPreview{
+			URL:         row.Url,
+			Title:       row.Title,
+			Description: row.Description,
+			ImageURL:    row.ImageUrl,
+			SiteName:    row.SiteName,
+		})
+	}
+	return byChirp, nil
+}
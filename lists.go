@@ -0,0 +1,430 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+const (
+	maxListNameLength        = 50
+	maxListDescriptionLength = 160
+	maxListsPerUser          = 100
+	maxListMembers           = 500
+)
+
+// List is a named set of accounts whose chirps make up its timeline.
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	OwnerID     uuid.UUID `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Private     bool      `json:"private"`
+	MemberCount int64     `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func listFromDB(row database.GetListRow) List {
+	return List{
+		ID:          row.ID,
+		OwnerID:     row.OwnerID,
+		Name:        row.Name,
+		Description: row.Description,
+		Private:     row.IsPrivate,
+		MemberCount: row.MemberCount,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}
+}
+
+type listParameters struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+func (p *listParameters) validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Description = strings.TrimSpace(p.Description)
+	if p.Name == "" {
+		return errors.New("List name is required")
+	}
+	if utf8.RuneCountInString(p.Name) > maxListNameLength {
+		return errors.New("List name is too long")
+	}
+	if utf8.RuneCountInString(p.Description) > maxListDescriptionLength {
+		return errors.New("List description is too long")
+	}
+	return nil
+}
+
+// viewableList loads the {listID} the request names, writing the error
+// response itself on failure. Other people's private lists are reported as
+// not found. Anonymous requests get uuid.Nil as the viewer.
+func (cfg *apiConfig) viewableList(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.GetListRow, bool) {
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return uuid.UUID{}, database.GetListRow{}, false
+	}
+
+	list, ok := cfg.loadList(w, r)
+	if !ok {
+		return uuid.UUID{}, database.GetListRow{}, false
+	}
+	if list.IsPrivate && list.OwnerID != viewerID {
+		respondWithError(w, http.StatusNotFound, "Couldn't find list", nil)
+		return uuid.UUID{}, database.GetListRow{}, false
+	}
+	return viewerID, list, true
+}
+
+// ownedList is viewableList for the management endpoints, which only the
+// list's owner may use.
+func (cfg *apiConfig) ownedList(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.GetListRow, bool) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return uuid.UUID{}, database.GetListRow{}, false
+	}
+
+	list, ok := cfg.loadList(w, r)
+	if !ok {
+		return uuid.UUID{}, database.GetListRow{}, false
+	}
+	if list.OwnerID != userID {
+		if list.IsPrivate {
+			respondWithError(w, http.StatusNotFound, "Couldn't find list", nil)
+		} else {
+			respondWithError(w, http.StatusForbidden, "You don't own this list", nil)
+		}
+		return uuid.UUID{}, database.GetListRow{}, false
+	}
+	return userID, list, true
+}
+
+func (cfg *apiConfig) loadList(w http.ResponseWriter, r *http.Request) (database.GetListRow, bool) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid list ID", err)
+		return database.GetListRow{}, false
+	}
+
+	list, err := cfg.db.GetList(r.Context(), listID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find list", err)
+		return database.GetListRow{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve list", err)
+		return database.GetListRow{}, false
+	}
+	return list, true
+}
+
+// respondWithList writes the current state of a list, with its member count.
+func (cfg *apiConfig) respondWithList(w http.ResponseWriter, r *http.Request, code int, listID uuid.UUID) {
+	list, err := cfg.db.GetList(r.Context(), listID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve list", err)
+		return
+	}
+	respondWithJSON(w, code, listFromDB(list))
+}
+
+func (cfg *apiConfig) handlerCreateList(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	params := listParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	count, err := cfg.db.CountListsByOwner(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create list", err)
+		return
+	}
+	if count >= maxListsPerUser {
+		respondWithError(w, http.StatusBadRequest, "You have too many lists", nil)
+		return
+	}
+
+	list, err := cfg.db.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:     userID,
+		Name:        params.Name,
+		Description: params.Description,
+		IsPrivate:   params.Private,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create list", err)
+		return
+	}
+
+	cfg.respondWithList(w, r, http.StatusCreated, list.ID)
+}
+
+// handlerMyLists lists the caller's own lists, private ones included.
+func (cfg *apiConfig) handlerMyLists(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+	cfg.listsByOwner(w, r, userID, true)
+}
+
+// handlerUserLists lists another user's public lists, or all of them when
+// the caller is that user.
+func (cfg *apiConfig) handlerUserLists(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+	cfg.listsByOwner(w, r, ownerID, viewerID == ownerID)
+}
+
+func (cfg *apiConfig) listsByOwner(w http.ResponseWriter, r *http.Request, ownerID uuid.UUID, includePrivate bool) {
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetListsByOwner(r.Context(), database.GetListsByOwnerParams{
+		OwnerID:        ownerID,
+		IncludePrivate: includePrivate,
+		PageSize:       limit,
+		PageOffset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve lists", err)
+		return
+	}
+
+	lists := []List{}
+	for _, row := range rows {
+		lists = append(lists, listFromDB(database.GetListRow(row)))
+	}
+
+	respondWithJSON(w, http.StatusOK, lists)
+}
+
+func (cfg *apiConfig) handlerGetList(w http.ResponseWriter, r *http.Request) {
+	_, list, ok := cfg.viewableList(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, listFromDB(list))
+}
+
+// handlerUpdateList replaces a list's name, description and privacy.
+func (cfg *apiConfig) handlerUpdateList(w http.ResponseWriter, r *http.Request) {
+	_, list, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	params := listParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	err := cfg.db.UpdateList(r.Context(), database.UpdateListParams{
+		ID:          list.ID,
+		Name:        params.Name,
+		Description: params.Description,
+		IsPrivate:   params.Private,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update list", err)
+		return
+	}
+
+	cfg.respondWithList(w, r, http.StatusOK, list.ID)
+}
+
+func (cfg *apiConfig) handlerDeleteList(w http.ResponseWriter, r *http.Request) {
+	_, list, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.db.DeleteList(r.Context(), list.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete list", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerListMembers(w http.ResponseWriter, r *http.Request) {
+	_, list, ok := cfg.viewableList(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetListMembers(r.Context(), database.GetListMembersParams{
+		ListID: list.ID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve list members", err)
+		return
+	}
+
+	users := []UserSummary{}
+	for _, row := range rows {
+		users = append(users, UserSummary{ID: row.ID, Username: row.Username})
+	}
+
+	respondWithJSON(w, http.StatusOK, followList{Count: list.MemberCount, Users: users})
+}
+
+// handlerAddListMember adds user_id to a list. Accounts that have blocked
+// the owner, or that the owner has blocked, can't be added.
+func (cfg *apiConfig) handlerAddListMember(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	userID, list, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), params.UserID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Could not find user", err)
+		return
+	}
+
+	blocked, err := cfg.blockedEitherWay(r.Context(), userID, params.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add list member", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You can't add this user", nil)
+		return
+	}
+
+	if list.MemberCount >= maxListMembers {
+		respondWithError(w, http.StatusBadRequest, "This list is full", nil)
+		return
+	}
+
+	_, err = cfg.db.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: params.UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't add list member", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRemoveListMember(w http.ResponseWriter, r *http.Request) {
+	_, list, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	removed, err := cfg.db.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: memberID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove list member", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "This user isn't on the list", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerListTimeline is a feed of chirps by the list's members, newest
+// first. It's built on read, so adding or removing a member changes the
+// whole timeline straight away.
+func (cfg *apiConfig) handlerListTimeline(w http.ResponseWriter, r *http.Request) {
+	viewerID, list, ok := cfg.viewableList(w, r)
+	if !ok {
+		return
+	}
+
+	before, limit, err := cursorPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetListTimeline(r.Context(), database.GetListTimelineParams{
+		ListID:   list.ID,
+		Before:   before,
+		PageSize: limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve timeline", err)
+		return
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), viewerID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve timeline", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
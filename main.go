@@ -1,13 +1,30 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"sync/atomic"
+	"syscall"
+	"time"
 	"github.com/joho/godotenv"
+	"workspace/github.com/Benjysparks/chirpy/internal/blobstore"
 	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/entitlements"
+	"workspace/github.com/Benjysparks/chirpy/internal/jobs"
+	"workspace/github.com/Benjysparks/chirpy/internal/linkpreview"
+	"workspace/github.com/Benjysparks/chirpy/internal/live"
+	"workspace/github.com/Benjysparks/chirpy/internal/media"
+	"workspace/github.com/Benjysparks/chirpy/internal/moderation"
+	"workspace/github.com/Benjysparks/chirpy/internal/ratelimit"
+	"workspace/github.com/Benjysparks/chirpy/internal/spam"
+	"workspace/github.com/Benjysparks/chirpy/internal/stream"
+	"workspace/github.com/Benjysparks/chirpy/internal/webhooks"
 	_ "github.com/lib/pq"
 )
 
@@ -15,9 +32,29 @@ import (
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db			   *database.Queries
+	dbConn		   *sql.DB
 	Platform	   string
 	JwtSecret	   string	
 	polkaKey	   string
+	polkaSecret	   string
+	timeline	   timelineReader
+	jobs		   *jobs.Queue
+	followerThreshold	int64
+	trends		   *trendsCache
+	moderation	   *moderation.Live
+	limiter		   *ratelimit.Limiter
+	// rateLimitBuckets is set when buckets are kept in Postgres.
+	rateLimitBuckets	*ratelimit.PostgresStore
+	trustProxy	   bool
+	spamThresholds	spam.Thresholds
+	blobs		   blobstore.Store
+	mediaLimits	   media.Limits
+	previews	   linkpreview.Fetcher
+	hub			   *stream.Hub
+	broker		   stream.Broker
+	sockets		   *live.Server
+	webhookSender  *webhooks.Sender
+	limitPolicy	   entitlements.Policy
 }
 
 func main() {
@@ -31,6 +68,13 @@ func main() {
 		log.Print("Cound not open connection to database")
 	}
 	dbQueries := database.New(db)
+	followerThreshold := int64(envInt("FANOUT_FOLLOWER_THRESHOLD", 10000))
+	jobQueue := jobs.NewQueue(dbQueries)
+
+	var timeline timelineReader = materializedTimeline{db: dbQueries, followerThreshold: followerThreshold}
+	if os.Getenv("TIMELINE_STRATEGY") == "fanout_on_read" {
+		timeline = fanOutOnReadTimeline{db: dbQueries}
+	}
 
 	const filepathRoot = "."
 	const port = "8080"
@@ -38,10 +82,70 @@ func main() {
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:				dbQueries,
+		dbConn:			db,
 		Platform:		PLATFORM,
 		JwtSecret:		JWTSecret,
 		polkaKey:		polkaKey,
+		polkaSecret:	os.Getenv("POLKA_WEBHOOK_SECRET"),
+		timeline:		timeline,
+		jobs:			jobQueue,
+		followerThreshold: followerThreshold,
+		trends:			newTrendsCache(trendsInterval),
+		moderation:		moderation.NewLive(),
+		previews:		linkpreview.NewClient(linkpreview.DefaultTimeout, linkpreview.DefaultMaxBytes),
+		webhookSender:	webhooks.NewSender(webhooks.DefaultTimeout),
+		limitPolicy:	loadLimitPolicy(),
+		trustProxy:		os.Getenv("TRUST_PROXY_HEADERS") == "true",
+		spamThresholds:	spam.Thresholds{
+			Hold:		envFloat("SPAM_HOLD_THRESHOLD", spam.DefaultThresholds.Hold),
+			Reject:		envFloat("SPAM_REJECT_THRESHOLD", spam.DefaultThresholds.Reject),
+			Throttle:	envFloat("SPAM_THROTTLE_THRESHOLD", spam.DefaultThresholds.Throttle),
+		},
+	}
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	blobs, err := blobstore.NewLocal(mediaDir)
+	if err != nil {
+		log.Fatalf("Couldn't open media store: %v", err)
 	}
+	apiCfg.blobs = blobs
+	apiCfg.mediaLimits = media.DefaultLimits
+	apiCfg.mediaLimits.MaxBytes = int64(envInt("MEDIA_MAX_BYTES", int(media.DefaultLimits.MaxBytes)))
+
+	apiCfg.hub = stream.NewHub(stream.DefaultBacklog)
+	apiCfg.broker = apiCfg.hub
+	if os.Getenv("STREAM_BROKER") == "postgres" {
+		broker := stream.NewPostgresBroker(dbQueries, apiCfg.hub)
+		apiCfg.broker = broker
+		go func() {
+			if err := broker.Listen(context.Background(), dbURL); err != nil {
+				log.Printf("Couldn't listen for stream events: %v", err)
+			}
+		}()
+	}
+	apiCfg.sockets = &live.Server{
+		Hub:		  apiCfg.hub,
+		Authenticate: apiCfg.socketUser,
+		Channel:	  apiCfg.socketChannel,
+	}
+
+	apiCfg.limiter = ratelimit.New(apiCfg.newRateLimitStore(os.Getenv("RATE_LIMIT_STORE")), apiCfg.rateLimitPrincipal)
+
+	workerPool := jobs.NewPool(jobQueue, envInt("JOB_WORKERS", 4))
+	apiCfg.registerJobs(workerPool)
+	go workerPool.Run(context.Background())
+	go jobQueue.Every(context.Background(), jobComputeTrends, trendsInterval)
+	go jobQueue.Every(context.Background(), jobPublishScheduled, scheduledChirpsInterval)
+	go jobQueue.Every(context.Background(), jobCollectMedia, collectMediaInterval)
+	go jobQueue.Every(context.Background(), jobExpireSubscriptions, expireSubscriptionsInterval)
+	go jobQueue.Every(context.Background(), jobPruneFailedJobs, time.Hour)
+	if apiCfg.rateLimitBuckets != nil {
+		go jobQueue.Every(context.Background(), jobPruneRateLimits, time.Hour)
+	}
+	apiCfg.loadModeration(context.Background())
+	go apiCfg.watchModeration(context.Background())
 
 	mux := http.NewServeMux()
 
@@ -50,18 +154,98 @@ func main() {
 	
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerChirpsValidate)
+	mux.HandleFunc("POST /api/chirps", apiCfg.limiter.Wrap(limitCreateChirp, apiCfg.handlerChirpsValidate))
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerChirpsRetrieve)
+	mux.HandleFunc("GET /api/chirps/scheduled", apiCfg.handlerScheduledChirps)
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerCreateDraft)
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerDrafts)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handlerUpdateDraft)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handlerDeleteDraft)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.limiter.Wrap(limitCreateChirp, apiCfg.handlerPublishDraft))
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerChirpsGet)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.handlerRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.handlerUndoRechirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.handlerBookmark)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.handlerRemoveBookmark)
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.handlerBookmarks)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}/schedule", apiCfg.handlerUpdateScheduledChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/schedule", apiCfg.handlerCancelScheduledChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.limiter.Wrap(limitReport, apiCfg.handlerReportChirp))
+
+	mux.HandleFunc("POST /api/media", apiCfg.limiter.Wrap(limitUploadMedia, apiCfg.handlerUploadMedia))
+	mux.HandleFunc("GET /api/media/{mediaID}", apiCfg.handlerGetMedia)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.handlerGetMediaThumbnail)
 
 	mux.HandleFunc("GET /api/showusers", apiCfg.handlerShowUsers)
-	mux.HandleFunc("POST /api/users", apiCfg.handlerAddUser)
+	mux.HandleFunc("POST /api/users", apiCfg.limiter.Wrap(limitCreateUser, apiCfg.handlerAddUser))
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerChangePassword)
+	mux.HandleFunc("POST /api/users/{id}/follow", apiCfg.limiter.Wrap(limitFollow, apiCfg.handlerFollow))
+	mux.HandleFunc("DELETE /api/users/{id}/follow", apiCfg.handlerUnfollow)
+	mux.HandleFunc("GET /api/users/{id}/followers", apiCfg.handlerFollowers)
+	mux.HandleFunc("GET /api/users/{id}/following", apiCfg.handlerFollowing)
+	mux.HandleFunc("GET /api/users/{id}/lists", apiCfg.handlerUserLists)
+	mux.HandleFunc("POST /api/users/{id}/report", apiCfg.limiter.Wrap(limitReport, apiCfg.handlerReportUser))
+	mux.HandleFunc("POST /api/users/{id}/block", apiCfg.handlerBlock)
+	mux.HandleFunc("DELETE /api/users/{id}/block", apiCfg.handlerUnblock)
+	mux.HandleFunc("POST /api/users/{id}/mute", apiCfg.handlerMute)
+	mux.HandleFunc("DELETE /api/users/{id}/mute", apiCfg.handlerUnmute)
+
+	mux.HandleFunc("GET /api/users/me/mentions", apiCfg.handlerMyMentions)
+	mux.HandleFunc("GET /api/users/me/subscription", apiCfg.handlerMySubscription)
+	mux.HandleFunc("GET /api/users/me/messaging", apiCfg.handlerMessageSettings)
+	mux.HandleFunc("PUT /api/users/me/messaging", apiCfg.handlerUpdateMessageSettings)
+	mux.HandleFunc("GET /api/limits", apiCfg.handlerLimits)
+	mux.HandleFunc("GET /api/users/me/blocks", apiCfg.handlerBlockedUsers)
+	mux.HandleFunc("GET /api/users/me/blocks/export", apiCfg.handlerExportBlockedUsers)
+	mux.HandleFunc("GET /api/users/me/mutes", apiCfg.handlerMutedUsers)
+	mux.HandleFunc("GET /api/users/me/mutes/export", apiCfg.handlerExportMutedUsers)
+
+	mux.HandleFunc("GET /api/notifications", apiCfg.handlerNotifications)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.handlerMarkAllNotificationsRead)
+	mux.HandleFunc("POST /api/notifications/{notificationID}/read", apiCfg.handlerMarkNotificationRead)
+	mux.HandleFunc("GET /api/notifications/preferences", apiCfg.handlerNotificationPreferences)
+	mux.HandleFunc("PUT /api/notifications/preferences", apiCfg.handlerUpdateNotificationPreferences)
+
+	mux.HandleFunc("POST /api/lists", apiCfg.handlerCreateList)
+	mux.HandleFunc("GET /api/lists", apiCfg.handlerMyLists)
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.handlerGetList)
+	mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.handlerUpdateList)
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.handlerDeleteList)
+	mux.HandleFunc("GET /api/lists/{listID}/members", apiCfg.handlerListMembers)
+	mux.HandleFunc("POST /api/lists/{listID}/members", apiCfg.handlerAddListMember)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.handlerRemoveListMember)
+	mux.HandleFunc("GET /api/lists/{listID}/timeline", apiCfg.handlerListTimeline)
+
+	mux.HandleFunc("POST /api/conversations", apiCfg.limiter.Wrap(limitStartConversation, apiCfg.handlerCreateConversation))
+	mux.HandleFunc("GET /api/conversations", apiCfg.handlerConversations)
+	mux.HandleFunc("GET /api/conversations/{conversationID}", apiCfg.handlerGetConversation)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.handlerConversationMessages)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.limiter.Wrap(limitSendMessage, apiCfg.handlerSendMessage))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.handlerMarkConversationRead)
+
+	mux.HandleFunc("POST /api/webhooks", apiCfg.handlerCreateWebhook)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.handlerWebhooks)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}", apiCfg.handlerGetWebhook)
+	mux.HandleFunc("PUT /api/webhooks/{webhookID}", apiCfg.handlerUpdateWebhook)
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", apiCfg.handlerDeleteWebhook)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", apiCfg.handlerWebhookDeliveries)
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries/{deliveryID}", apiCfg.handlerWebhookDelivery)
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", apiCfg.handlerRedeliverWebhook)
 
-	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("GET /api/timeline", apiCfg.handlerTimeline)
+	mux.HandleFunc("GET /api/stream", apiCfg.handlerStream)
+	mux.HandleFunc("GET /api/ws", apiCfg.handlerSocket)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", apiCfg.handlerHashtagChirps)
 
-	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
+	mux.HandleFunc("GET /api/trends", apiCfg.handlerTrends)
+	mux.HandleFunc("GET /api/trends/suppressed", apiCfg.handlerSuppressedTags)
+	mux.HandleFunc("POST /api/trends/suppressed", apiCfg.handlerSuppressTag)
+	mux.HandleFunc("DELETE /api/trends/suppressed/{tag}", apiCfg.handlerUnsuppressTag)
+
+	mux.HandleFunc("POST /api/login", apiCfg.limiter.Wrap(limitLogin, apiCfg.handlerLogin))
+
+	mux.HandleFunc("POST /api/refresh", apiCfg.limiter.Wrap(limitRefresh, apiCfg.handlerRefresh))
 
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
 
@@ -69,14 +253,82 @@ func main() {
 
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
 
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgradeAccount)
+	mux.HandleFunc("GET /admin/moderation/filters", apiCfg.handlerListModerationFilters)
+	mux.HandleFunc("POST /admin/moderation/filters", apiCfg.handlerCreateModerationFilter)
+	mux.HandleFunc("PUT /admin/moderation/filters/{filterID}", apiCfg.handlerUpdateModerationFilter)
+	mux.HandleFunc("DELETE /admin/moderation/filters/{filterID}", apiCfg.handlerDeleteModerationFilter)
+	mux.HandleFunc("POST /admin/moderation/filters/{filterID}/terms", apiCfg.handlerAddModerationTerms)
+	mux.HandleFunc("DELETE /admin/moderation/filters/{filterID}/terms/{term}", apiCfg.handlerDeleteModerationTerm)
+	mux.HandleFunc("GET /admin/moderation/held", apiCfg.handlerHeldChirps)
+	mux.HandleFunc("POST /admin/moderation/held/{chirpID}/approve", apiCfg.handlerApproveHeldChirp)
+	mux.HandleFunc("POST /admin/moderation/held/{chirpID}/reject", apiCfg.handlerRejectHeldChirp)
+	mux.HandleFunc("PUT /admin/users/{id}/state", apiCfg.handlerSetAccountState)
+	mux.HandleFunc("GET /admin/users/{id}/state-changes", apiCfg.handlerAccountStateChanges)
+	mux.HandleFunc("GET /admin/spam/checks", apiCfg.handlerSpamChecks)
+	mux.HandleFunc("GET /admin/reports", apiCfg.handlerListReports)
+	mux.HandleFunc("GET /admin/reports/{reportID}", apiCfg.handlerGetReport)
+	mux.HandleFunc("POST /admin/reports/{reportID}/claim", apiCfg.handlerClaimReport)
+	mux.HandleFunc("POST /admin/reports/{reportID}/resolve", apiCfg.handlerResolveReport)
+
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.limiter.Wrap(limitPolkaWebhook, apiCfg.handlerPolkaWebhook))
+	mux.HandleFunc("GET /admin/polka/webhooks", apiCfg.handlerPolkaWebhookLog)
 
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
 	}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+	// Streams and sockets never go idle, so they're closed explicitly on
+	// shutdown: sockets first, so they get CloseGoingAway rather than
+	// looking like slow clients when the hub closes.
+	srv.RegisterOnShutdown(apiCfg.hub.CloseAll)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+
+	log.Print("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := apiCfg.sockets.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Couldn't close all sockets: %v", err)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Couldn't shut down cleanly: %v", err)
+	}
+}
+
+// envInt reads an integer setting from the environment, falling back to def
+// when it is unset or malformed.
+func envInt(name string, def int) int {
+	s := os.Getenv(name)
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q", name, s)
+		return def
+	}
+	return n
+}
 
+// envFloat is envInt for decimal settings.
+func envFloat(name string, def float64) float64 {
+	s := os.Getenv(name)
+	if s == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		log.Printf("Ignoring invalid %s=%q", name, s)
+		return def
+	}
+	return f
 }
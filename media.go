@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/media"
+)
+
+const (
+	jobCollectMedia = "media.collect"
+	// unattachedMediaGrace is how long an upload may sit without a chirp
+	// before it is deleted.
+	unattachedMediaGrace  = 24 * time.Hour
+	collectMediaInterval  = time.Hour
+	collectMediaBatchSize = 500
+	attachmentFormField   = "file"
+	multipartOverhead     = 64 << 10
+)
+
+var errUnknownAttachment = errors.New("unknown attachment")
+
+type Attachment struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+func attachmentFromDB(a database.Attachment) Attachment {
+	return Attachment{
+		ID:           a.ID,
+		ContentType:  a.ContentType,
+		SizeBytes:    a.SizeBytes,
+		Width:        a.Width,
+		Height:       a.Height,
+		URL:          "/api/media/" + a.ID.String(),
+		ThumbnailURL: "/api/media/" + a.ID.String() + "/thumbnail",
+	}
+}
+
+// loadAttachments fetches the attachments of every chirp in chirpIDs, in
+// the order their author gave them.
+func (cfg *apiConfig) loadAttachments(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]Attachment, error) {
+	byChirp := map[uuid.UUID][]Attachment{}
+	if len(chirpIDs) == 0 {
+		return byChirp, nil
+	}
+
+	rows, err := cfg.db.GetChirpAttachments(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		byChirp[row.ChirpID.UUID] = append(byChirp[row.ChirpID.UUID], attachmentFromDB(row))
+	}
+	return byChirp, nil
+}
+
+// attachToChirp links uploads to a new chirp. Every ID must be an unattached
+// upload owned by userID, or errUnknownAttachment is returned.
+func attachToChirp(ctx context.Context, q *database.Queries, chirp database.Chirp, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	attached, err := q.AttachToChirp(ctx, database.AttachToChirpParams{
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Ids:     ids,
+		UserID:  chirp.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	if attached != int64(len(ids)) {
+		return errUnknownAttachment
+	}
+	return nil
+}
+
+// validateAttachmentIDs checks the attachment list of a new chirp. How many
+// attachments are allowed depends on the author's plan and is checked
+// separately.
+func validateAttachmentIDs(ids []uuid.UUID) error {
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			return errors.New("attachments can't be repeated")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// handlerUploadMedia accepts one image as the "file" field of a multipart
+// form. The upload stays private to its owner until it's attached to a
+// published chirp.
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.authenticatedUser(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.mediaLimits.MaxBytes+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Expected a multipart form", err)
+		return
+	}
+
+	var data []byte
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't read upload", err)
+			return
+		}
+		if part.FormName() != attachmentFormField {
+			continue
+		}
+		data, err = io.ReadAll(io.LimitReader(part, cfg.mediaLimits.MaxBytes+1))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't read upload", err)
+			return
+		}
+		break
+	}
+	if data == nil {
+		respondWithError(w, http.StatusBadRequest, "Missing file field", nil)
+		return
+	}
+
+	img, err := media.Process(data, cfg.mediaLimits)
+	if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Image is too large", err)
+		return
+	}
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are supported", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't process image", err)
+		return
+	}
+
+	attachment, err := cfg.storeAttachment(r.Context(), user.ID, img)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save upload", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, attachmentFromDB(attachment))
+}
+
+// storeAttachment writes both blobs before the row that points at them, and
+// cleans the blobs up again if the row can't be written.
+func (cfg *apiConfig) storeAttachment(ctx context.Context, userID uuid.UUID, img media.Image) (database.Attachment, error) {
+	id := uuid.New()
+	blobKey := "attachments/" + id.String()
+	thumbnailKey := blobKey + "-thumb"
+
+	if err := cfg.blobs.Put(ctx, blobKey, img.ContentType, bytes.NewReader(img.Data)); err != nil {
+		return database.Attachment{}, err
+	}
+	if err := cfg.blobs.Put(ctx, thumbnailKey, img.ThumbnailContentType, bytes.NewReader(img.Thumbnail)); err != nil {
+		cfg.deleteBlobs(ctx, blobKey)
+		return database.Attachment{}, err
+	}
+
+	attachment, err := cfg.db.CreateAttachment(ctx, database.CreateAttachmentParams{
+		ID:                   id,
+		UserID:               userID,
+		ContentType:          img.ContentType,
+		SizeBytes:            int64(len(img.Data)),
+		Width:                int32(img.Width),
+		Height:               int32(img.Height),
+		BlobKey:              blobKey,
+		ThumbnailKey:         thumbnailKey,
+		ThumbnailContentType: img.ThumbnailContentType,
+	})
+	if err != nil {
+		cfg.deleteBlobs(ctx, blobKey, thumbnailKey)
+		return database.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := cfg.blobs.Delete(ctx, key); err != nil {
+			log.Printf("Couldn't delete blob %s: %v", key, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerGetMedia(w http.ResponseWriter, r *http.Request) {
+	cfg.serveAttachment(w, r, false)
+}
+
+func (cfg *apiConfig) handlerGetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveAttachment(w, r, true)
+}
+
+// serveAttachment streams an attachment from the blob store. Attachments on
+// published chirps are public; anything else is only served to its owner.
+func (cfg *apiConfig) serveAttachment(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	id, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media ID", err)
+		return
+	}
+
+	attachment, err := cfg.db.GetAttachment(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+		return
+	}
+
+	public := false
+	if attachment.ChirpID.Valid {
+		chirp, err := cfg.db.GetChirpsByID(r.Context(), attachment.ChirpID.UUID)
+		public = err == nil && chirp.Status == chirpStatusPublished
+	}
+	if !public {
+		viewerID, err := cfg.optionalUserID(r)
+		if err != nil || viewerID != attachment.UserID {
+			respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+			return
+		}
+	}
+
+	key, contentType := attachment.BlobKey, attachment.ContentType
+	if thumbnail {
+		key, contentType = attachment.ThumbnailKey, attachment.ThumbnailContentType
+	}
+	blob, err := cfg.blobs.Open(r.Context(), key)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find media", err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if public {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+// jobCollectMedia deletes uploads that were never attached to a chirp, or
+// whose chirp was deleted, once they are past the grace period.
+func (cfg *apiConfig) jobCollectMedia(ctx context.Context, _ json.RawMessage) error {
+	for {
+		rows, err := cfg.db.GetUnattachedAttachments(ctx, database.GetUnattachedAttachmentsParams{
+			UpdatedAt: time.Now().UTC().Add(-unattachedMediaGrace),
+			Limit:     collectMediaBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			// The row only goes if it's still unattached, so an upload
+			// attached in the meantime keeps its blobs.
+			deleted, err := cfg.db.DeleteUnattachedAttachment(ctx, row.ID)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				cfg.deleteBlobs(ctx, row.BlobKey, row.ThumbnailKey)
+			}
+		}
+		if len(rows) < collectMediaBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+// Direct messages live in their own tables and are only ever read through
+// the /api/conversations endpoints, by members of the conversation. They
+// are never streamed, extracted for hashtags or mentions, or sent to
+// webhooks, so nothing private can leak into a public feed. Shadow-banned
+// users can't send them: their recipients would see the messages, which
+// gives the ban away. They are refused with the same errors a recipient's
+// settings or a block produce.
+
+const (
+	// maxConversationMembers includes the user who starts the conversation.
+	maxConversationMembers = 10
+	maxMessageLength       = 1000
+
+	messagesFromEveryone  = "everyone"
+	messagesFromFollowing = "following"
+)
+
+type ConversationMember struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	// LastReadAt is the member's read receipt: every message sent up to
+	// then has been read.
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Conversation struct {
+	ID            uuid.UUID            `json:"id"`
+	Members       []ConversationMember `json:"members"`
+	LastMessage   *Message             `json:"last_message"`
+	UnreadCount   int64                `json:"unread_count"`
+	CreatedAt     time.Time            `json:"created_at"`
+	LastMessageAt time.Time            `json:"last_message_at"`
+}
+
+func messageFromDB(row database.Message) Message {
+	return Message{
+		ID:             row.ID,
+		ConversationID: row.ConversationID,
+		SenderID:       row.SenderID,
+		Body:           row.Body,
+		CreatedAt:      row.CreatedAt,
+	}
+}
+
+// directKey identifies the one-to-one conversation between a and b, whichever
+// of them starts it.
+func directKey(a, b uuid.UUID) string {
+	x, y := a.String(), b.String()
+	if y < x {
+		x, y = y, x
+	}
+	return x + ":" + y
+}
+
+func validateMessageBody(body string) error {
+	if strings.TrimSpace(body) == "" {
+		return errors.New("Message can't be empty")
+	}
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		return errors.New("Message is too long")
+	}
+	return nil
+}
+
+// conversationTarget authenticates the request and loads the
+// {conversationID} it names, writing the error response itself on failure.
+// Conversations the caller isn't a member of are reported as not found.
+func (cfg *apiConfig) conversationTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Conversation, bool) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return uuid.UUID{}, database.Conversation{}, false
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid conversation ID", err)
+		return uuid.UUID{}, database.Conversation{}, false
+	}
+
+	conversation, err := cfg.db.GetConversationForMember(r.Context(), database.GetConversationForMemberParams{
+		ID:     conversationID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find conversation", err)
+		return uuid.UUID{}, database.Conversation{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve conversation", err)
+		return uuid.UUID{}, database.Conversation{}, false
+	}
+	return userID, conversation, true
+}
+
+// sendMessage adds a message to a conversation and moves the sender's read
+// receipt up to it. q should be a transaction's queries.
+func sendMessage(ctx context.Context, q *database.Queries, conversationID, senderID uuid.UUID, body string) (database.Message, error) {
+	message, err := q.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	err = q.TouchConversation(ctx, database.TouchConversationParams{
+		ID:            conversationID,
+		LastMessageAt: message.CreatedAt,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	err = q.MarkConversationRead(ctx, database.MarkConversationReadParams{
+		ReadAt:         message.CreatedAt,
+		ConversationID: conversationID,
+		UserID:         senderID,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+	return message, nil
+}
+
+// conversationMembers returns the members of each conversation, keyed by
+// conversation ID.
+func (cfg *apiConfig) conversationMembers(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]ConversationMember, error) {
+	rows, err := cfg.db.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	members := map[uuid.UUID][]ConversationMember{}
+	for _, row := range rows {
+		member := ConversationMember{ID: row.ID, Username: row.Username}
+		if row.LastReadAt.Valid {
+			member.LastReadAt = &row.LastReadAt.Time
+		}
+		members[row.ConversationID] = append(members[row.ConversationID], member)
+	}
+	return members, nil
+}
+
+// expandConversations attaches members and the latest message to each
+// conversation.
+func (cfg *apiConfig) expandConversations(ctx context.Context, rows []database.GetConversationsForUserRow) ([]Conversation, error) {
+	conversations := []Conversation{}
+	if len(rows) == 0 {
+		return conversations, nil
+	}
+
+	ids := []uuid.UUID{}
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	members, err := cfg.conversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	latestRows, err := cfg.db.GetLatestMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	latest := map[uuid.UUID]Message{}
+	for _, row := range latestRows {
+		latest[row.ConversationID] = messageFromDB(row)
+	}
+
+	for _, row := range rows {
+		c := Conversation{
+			ID:            row.ID,
+			Members:       members[row.ID],
+			UnreadCount:   row.UnreadCount,
+			CreatedAt:     row.CreatedAt,
+			LastMessageAt: row.LastMessageAt,
+		}
+		if message, ok := latest[row.ID]; ok {
+			c.LastMessage = &message
+		}
+		conversations = append(conversations, c)
+	}
+	return conversations, nil
+}
+
+// respondWithConversation writes one conversation as viewerID sees it.
+func (cfg *apiConfig) respondWithConversation(w http.ResponseWriter, r *http.Request, code int, viewerID uuid.UUID, conversation database.Conversation) {
+	unread, err := cfg.db.CountUnreadMessages(r.Context(), database.CountUnreadMessagesParams{
+		ConversationID: conversation.ID,
+		UserID:         viewerID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve conversation", err)
+		return
+	}
+
+	conversations, err := cfg.expandConversations(r.Context(), []database.GetConversationsForUserRow{{
+		ID:            conversation.ID,
+		CreatedAt:     conversation.CreatedAt,
+		LastMessageAt: conversation.LastMessageAt,
+		UnreadCount:   unread,
+	}})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve conversation", err)
+		return
+	}
+	respondWithJSON(w, code, conversations[0])
+}
+
+// handlerCreateConversation starts a conversation with member_ids, and sends
+// body as its first message if one is given. Each recipient must accept
+// messages from the caller. Starting a one-to-one conversation that already
+// exists returns the existing one.
+func (cfg *apiConfig) handlerCreateConversation(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+		Body      string      `json:"body"`
+	}
+
+	user, err := cfg.authenticatedUser(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+	userID := user.ID
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	seen := map[uuid.UUID]bool{userID: true}
+	recipients := []uuid.UUID{}
+	for _, id := range params.MemberIDs {
+		if !seen[id] {
+			seen[id] = true
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		respondWithError(w, http.StatusBadRequest, "A conversation needs at least one other member", nil)
+		return
+	}
+	if len(recipients)+1 > maxConversationMembers {
+		respondWithError(w, http.StatusBadRequest, "Too many members", nil)
+		return
+	}
+	if params.Body != "" {
+		if err := validateMessageBody(params.Body); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+	}
+
+	found, err := cfg.db.CountUsersByIDs(r.Context(), recipients)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start conversation", err)
+		return
+	}
+	if found != int64(len(recipients)) {
+		respondWithError(w, http.StatusNotFound, "Could not find user", nil)
+		return
+	}
+
+	refused, err := cfg.db.GetMessageRefusals(r.Context(), database.GetMessageRefusalsParams{
+		RecipientIds: recipients,
+		SenderID:     userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start conversation", err)
+		return
+	}
+	if len(refused) > 0 || user.AccountState == accountShadowBanned {
+		respondWithError(w, http.StatusForbidden, "You can't message this user", nil)
+		return
+	}
+
+	key := sql.NullString{}
+	if len(recipients) == 1 {
+		key = sql.NullString{String: directKey(userID, recipients[0]), Valid: true}
+	}
+
+	code := http.StatusCreated
+	if key.Valid {
+		_, err := cfg.db.GetDirectConversation(r.Context(), key)
+		if err == nil {
+			code = http.StatusOK
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't start conversation", err)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start conversation", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	// CreateConversation hands back the existing row when two requests race
+	// to start the same one-to-one conversation.
+	conversation, err := q.CreateConversation(r.Context(), database.CreateConversationParams{
+		CreatedBy: userID,
+		DirectKey: key,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start conversation", err)
+		return
+	}
+	for _, memberID := range append([]uuid.UUID{userID}, recipients...) {
+		err := q.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+			ConversationID: conversation.ID,
+			UserID:         memberID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't start conversation", err)
+			return
+		}
+	}
+	if params.Body != "" {
+		message, err := sendMessage(r.Context(), q, conversation.ID, userID, params.Body)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+			return
+		}
+		conversation.LastMessageAt = message.CreatedAt
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start conversation", err)
+		return
+	}
+
+	cfg.respondWithConversation(w, r, code, userID, conversation)
+}
+
+// handlerConversations lists the caller's conversations, most recent
+// activity first, with how many have unread messages.
+func (cfg *apiConfig) handlerConversations(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		UnreadCount   int64          `json:"unread_count"`
+		Conversations []Conversation `json:"conversations"`
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetConversationsForUser(r.Context(), database.GetConversationsForUserParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve conversations", err)
+		return
+	}
+
+	conversations, err := cfg.expandConversations(r.Context(), rows)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve conversations", err)
+		return
+	}
+
+	unread, err := cfg.db.CountUnreadConversations(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve conversations", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{UnreadCount: unread, Conversations: conversations})
+}
+
+func (cfg *apiConfig) handlerGetConversation(w http.ResponseWriter, r *http.Request) {
+	userID, conversation, ok := cfg.conversationTarget(w, r)
+	if !ok {
+		return
+	}
+	cfg.respondWithConversation(w, r, http.StatusOK, userID, conversation)
+}
+
+// handlerConversationMessages pages through a conversation's messages,
+// newest first. Members are included so clients can show read receipts.
+func (cfg *apiConfig) handlerConversationMessages(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Members  []ConversationMember `json:"members"`
+		Messages []Message            `json:"messages"`
+	}
+
+	_, conversation, ok := cfg.conversationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	before, limit, err := cursorPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversation.ID,
+		CreatedAt:      before,
+		Limit:          limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve messages", err)
+		return
+	}
+	messages := []Message{}
+	for _, row := range rows {
+		messages = append(messages, messageFromDB(row))
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve messages", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Members: members[conversation.ID], Messages: messages})
+}
+
+// handlerSendMessage posts to a conversation. Blocks are checked on every
+// message, so a member who blocks another after the conversation started
+// stops hearing from them; the messaging setting only governs who may
+// start one. In a group, a block between the sender and any one member
+// stops the sender posting to the whole group: every member sees the same
+// messages, so there is no way to leave the blocking member out.
+func (cfg *apiConfig) handlerSendMessage(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, conversation, ok := cfg.conversationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if err := validateMessageBody(params.Body); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	sender, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+	blocked, err := cfg.db.HasBlockedConversationMember(r.Context(), database.HasBlockedConversationMemberParams{
+		SenderID:       userID,
+		ConversationID: conversation.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+	if blocked || sender.AccountState == accountShadowBanned {
+		respondWithError(w, http.StatusForbidden, "You can't message this conversation", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+	defer tx.Rollback()
+
+	message, err := sendMessage(r.Context(), cfg.db.WithTx(tx), conversation.ID, userID, params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, messageFromDB(message))
+}
+
+// handlerMarkConversationRead moves the caller's read receipt up to the
+// latest message.
+func (cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	userID, conversation, ok := cfg.conversationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	latest, err := cfg.db.GetLatestMessages(r.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update conversation", err)
+		return
+	}
+	if len(latest) > 0 {
+		err := cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ReadAt:         latest[0].CreatedAt,
+			ConversationID: conversation.ID,
+			UserID:         userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update conversation", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type messageSettings struct {
+	AllowMessagesFrom string `json:"allow_messages_from"`
+}
+
+func (cfg *apiConfig) handlerMessageSettings(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	allowFrom, err := cfg.db.GetMessageSetting(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve settings", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, messageSettings{AllowMessagesFrom: allowFrom})
+}
+
+// handlerUpdateMessageSettings sets who may start a conversation with the
+// caller: "everyone", or "following" for only the accounts they follow.
+func (cfg *apiConfig) handlerUpdateMessageSettings(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	params := messageSettings{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.AllowMessagesFrom != messagesFromEveryone && params.AllowMessagesFrom != messagesFromFollowing {
+		respondWithError(w, http.StatusBadRequest, `allow_messages_from must be "everyone" or "following"`, nil)
+		return
+	}
+
+	err = cfg.db.SetMessageSetting(r.Context(), database.SetMessageSettingParams{
+		UserID:    userID,
+		AllowFrom: params.AllowMessagesFrom,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update settings", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, params)
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/moderation"
+)
+
+// moderationReloadInterval bounds how long a filter change made through
+// another server instance takes to reach this one.
+const moderationReloadInterval = 30 * time.Second
+
+// fallbackModeration is the word list that was hard-coded before filters
+// moved to the database. It is used if they can't be loaded at startup, so
+// a database problem doesn't switch moderation off.
+var fallbackModeration = []moderation.Config{{
+	Name:   "profanity",
+	Kind:   moderation.KindWordList,
+	Action: moderation.ActionMask,
+	Terms:  []string{"kerfuffle", "sharbert", "fornax"},
+}}
+
+// loadModeration installs the first pipeline, from the database if it can
+// and from fallbackModeration otherwise.
+func (cfg *apiConfig) loadModeration(ctx context.Context) {
+	err := cfg.reloadModeration(ctx)
+	if err == nil {
+		return
+	}
+	log.Printf("Couldn't load moderation filters, using the built-in word list: %v", err)
+	pipeline, err := moderation.NewPipeline(fallbackModeration)
+	if err != nil {
+		log.Printf("Couldn't build the built-in moderation filters: %v", err)
+		return
+	}
+	cfg.moderation.Swap(pipeline)
+}
+
+// reloadModeration rebuilds the moderation pipeline from the database and
+// swaps it in. Requests in flight keep the pipeline they started with, and
+// if the rebuild fails the current pipeline stays in place.
+func (cfg *apiConfig) reloadModeration(ctx context.Context) error {
+	filters, err := cfg.db.GetModerationFilters(ctx)
+	if err != nil {
+		return err
+	}
+	terms, err := cfg.db.GetModerationTerms(ctx)
+	if err != nil {
+		return err
+	}
+
+	termsByFilter := map[uuid.UUID][]string{}
+	for _, t := range terms {
+		termsByFilter[t.FilterID] = append(termsByFilter[t.FilterID], t.Term)
+	}
+
+	configs := []moderation.Config{}
+	for _, f := range filters {
+		if !f.Enabled {
+			continue
+		}
+		configs = append(configs, moderationConfig(f, termsByFilter[f.ID]))
+	}
+
+	pipeline, err := moderation.NewPipeline(configs)
+	if err != nil {
+		return err
+	}
+	cfg.moderation.Swap(pipeline)
+	return nil
+}
+
+// watchModeration reloads the pipeline periodically until ctx is cancelled.
+func (cfg *apiConfig) watchModeration(ctx context.Context) {
+	ticker := time.NewTicker(moderationReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := cfg.reloadModeration(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Couldn't reload moderation filters: %v", err)
+		}
+	}
+}
+
+func moderationConfig(f database.ModerationFilter, terms []string) moderation.Config {
+	return moderation.Config{
+		ID:      f.ID.String(),
+		Name:    f.Name,
+		Kind:    moderation.Kind(f.Kind),
+		Action:  moderation.Action(f.Action),
+		Pattern: f.Pattern,
+		Terms:   terms,
+	}
+}
+
+type ModerationFilter struct {
+	ID        uuid.UUID `json:"id"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Action    string    `json:"action"`
+	Pattern   string    `json:"pattern,omitempty"`
+	Position  int32     `json:"position"`
+	Enabled   bool      `json:"enabled"`
+	Terms     []string  `json:"terms"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func moderationFilterFromDB(f database.ModerationFilter, terms []string) ModerationFilter {
+	if terms == nil {
+		terms = []string{}
+	}
+	return ModerationFilter{
+		ID:        f.ID,
+		Kind:      f.Kind,
+		Name:      f.Name,
+		Action:    f.Action,
+		Pattern:   f.Pattern,
+		Position:  f.Position,
+		Enabled:   f.Enabled,
+		Terms:     terms,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+// applyModerationChange reloads the pipeline after an admin edit so the
+// change takes effect on this instance immediately.
+func (cfg *apiConfig) applyModerationChange(ctx context.Context) {
+	if err := cfg.reloadModeration(ctx); err != nil {
+		log.Printf("Couldn't reload moderation filters: %v", err)
+	}
+}
+
+func (cfg *apiConfig) handlerListModerationFilters(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	filters, err := cfg.db.GetModerationFilters(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve filters", err)
+		return
+	}
+	terms, err := cfg.db.GetModerationTerms(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve filters", err)
+		return
+	}
+
+	termsByFilter := map[uuid.UUID][]string{}
+	for _, t := range terms {
+		termsByFilter[t.FilterID] = append(termsByFilter[t.FilterID], t.Term)
+	}
+
+	out := []ModerationFilter{}
+	for _, f := range filters {
+		out = append(out, moderationFilterFromDB(f, termsByFilter[f.ID]))
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) handlerCreateModerationFilter(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Kind     string   `json:"kind"`
+		Name     string   `json:"name"`
+		Action   string   `json:"action"`
+		Pattern  string   `json:"pattern"`
+		Position int32    `json:"position"`
+		Enabled  *bool    `json:"enabled"`
+		Terms    []string `json:"terms"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	terms := cleanTerms(params.Terms)
+	err := moderation.Validate(moderation.Config{
+		Kind:    moderation.Kind(params.Kind),
+		Action:  moderation.Action(params.Action),
+		Pattern: params.Pattern,
+		Terms:   terms,
+	})
+	if err != nil || strings.TrimSpace(params.Name) == "" {
+		if err == nil {
+			err = errors.New("name is required")
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid filter: "+err.Error(), err)
+		return
+	}
+
+	enabled := true
+	if params.Enabled != nil {
+		enabled = *params.Enabled
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create filter", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	filter, err := q.CreateModerationFilter(r.Context(), database.CreateModerationFilterParams{
+		Kind:     params.Kind,
+		Name:     params.Name,
+		Action:   params.Action,
+		Pattern:  params.Pattern,
+		Position: params.Position,
+		Enabled:  enabled,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create filter", err)
+		return
+	}
+	for _, term := range terms {
+		err := q.AddModerationTerm(r.Context(), database.AddModerationTermParams{FilterID: filter.ID, Term: term})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create filter", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create filter", err)
+		return
+	}
+
+	cfg.applyModerationChange(r.Context())
+	respondWithJSON(w, http.StatusCreated, moderationFilterFromDB(filter, terms))
+}
+
+func (cfg *apiConfig) handlerUpdateModerationFilter(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name     *string `json:"name"`
+		Action   *string `json:"action"`
+		Pattern  *string `json:"pattern"`
+		Position *int32  `json:"position"`
+		Enabled  *bool   `json:"enabled"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	filterID, err := uuid.Parse(r.PathValue("filterID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid filter ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	existing, err := cfg.db.GetModerationFilter(r.Context(), filterID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find filter", err)
+		return
+	}
+
+	update := database.UpdateModerationFilterParams{
+		ID:       existing.ID,
+		Name:     existing.Name,
+		Action:   existing.Action,
+		Pattern:  existing.Pattern,
+		Position: existing.Position,
+		Enabled:  existing.Enabled,
+	}
+	if params.Name != nil {
+		update.Name = *params.Name
+	}
+	if params.Action != nil {
+		update.Action = *params.Action
+	}
+	if params.Pattern != nil {
+		update.Pattern = *params.Pattern
+	}
+	if params.Position != nil {
+		update.Position = *params.Position
+	}
+	if params.Enabled != nil {
+		update.Enabled = *params.Enabled
+	}
+
+	err = moderation.Validate(moderation.Config{
+		Kind:    moderation.Kind(existing.Kind),
+		Action:  moderation.Action(update.Action),
+		Pattern: update.Pattern,
+	})
+	if err != nil || strings.TrimSpace(update.Name) == "" {
+		if err == nil {
+			err = errors.New("name is required")
+		}
+		respondWithError(w, http.StatusBadRequest, "Invalid filter: "+err.Error(), err)
+		return
+	}
+
+	filter, err := cfg.db.UpdateModerationFilter(r.Context(), update)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update filter", err)
+		return
+	}
+	terms, err := cfg.db.GetModerationFilterTerms(r.Context(), filter.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve filter", err)
+		return
+	}
+
+	cfg.applyModerationChange(r.Context())
+	respondWithJSON(w, http.StatusOK, moderationFilterFromDB(filter, terms))
+}
+
+func (cfg *apiConfig) handlerDeleteModerationFilter(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	filterID, err := uuid.Parse(r.PathValue("filterID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid filter ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteModerationFilter(r.Context(), filterID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete filter", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find filter", nil)
+		return
+	}
+
+	cfg.applyModerationChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerAddModerationTerms(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Terms []string `json:"terms"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	filterID, err := uuid.Parse(r.PathValue("filterID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid filter ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	filter, err := cfg.db.GetModerationFilter(r.Context(), filterID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find filter", err)
+		return
+	}
+	if filter.Kind == string(moderation.KindRegex) {
+		respondWithError(w, http.StatusBadRequest, "Regex filters don't have terms", nil)
+		return
+	}
+
+	for _, term := range cleanTerms(params.Terms) {
+		err := cfg.db.AddModerationTerm(r.Context(), database.AddModerationTermParams{FilterID: filterID, Term: term})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't add terms", err)
+			return
+		}
+	}
+
+	cfg.applyModerationChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerDeleteModerationTerm(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	filterID, err := uuid.Parse(r.PathValue("filterID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid filter ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteModerationTerm(r.Context(), database.DeleteModerationTermParams{
+		FilterID: filterID,
+		Term:     strings.ToLower(r.PathValue("term")),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete term", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find term", nil)
+		return
+	}
+
+	cfg.applyModerationChange(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func cleanTerms(terms []string) []string {
+	cleaned := []string{}
+	for _, t := range terms {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			cleaned = append(cleaned, t)
+		}
+	}
+	return cleaned
+}
+
+type HeldChirp struct {
+	Chirp
+	Reasons string `json:"reasons"`
+}
+
+func (cfg *apiConfig) handlerHeldChirps(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	rows, err := cfg.db.GetHeldChirps(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve held chirps", err)
+		return
+	}
+
+	held := []HeldChirp{}
+	for _, row := range rows {
+		held = append(held, HeldChirp{
+			Chirp: chirpFromDB(database.Chirp{
+				ID:        row.ID,
+				CreatedAt: row.CreatedAt,
+				UpdatedAt: row.UpdatedAt,
+				Body:      row.Body,
+				UserID:    row.UserID,
+				RechirpOf: row.RechirpOf,
+				QuoteOf:   row.QuoteOf,
+				IsQuote:   row.IsQuote,
+				Status:    row.Status,
+			}),
+			Reasons: row.Reasons,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, held)
+}
+
+func (cfg *apiConfig) handlerApproveHeldChirp(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	chirp, err := q.ReleaseHeldChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't find held chirp", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+	if err := q.ClearChirpHold(r.Context(), chirpID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't approve chirp", err)
+		return
+	}
+
+	if chirp.Status == chirpStatusPublished {
+		cfg.afterChirpCreated(r.Context(), chirp)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRejectHeldChirp(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteHeldChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reject chirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find held chirp", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
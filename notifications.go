@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/stream"
+)
+
+const (
+	notificationFollow  = "follow"
+	notificationMention = "mention"
+	notificationRechirp = "rechirp"
+	notificationQuote   = "quote"
+
+	jobNotify      = "notifications.send"
+	jobNotifyChirp = "notifications.chirp"
+
+	// notificationActorsShown is how many actors a grouped notification
+	// names before summarising the rest as "N others".
+	notificationActorsShown = 3
+)
+
+var notificationTypes = []string{notificationFollow, notificationMention, notificationRechirp, notificationQuote}
+
+var notificationVerbs = map[string]string{
+	notificationFollow:  "followed you",
+	notificationMention: "mentioned you",
+	notificationRechirp: "rechirped your chirp",
+	notificationQuote:   "quoted your chirp",
+}
+
+// notificationEvent is one thing that happened to RecipientID. ChirpID is
+// the recipient's chirp for rechirps and quotes, and the mentioning chirp
+// for mentions.
+type notificationEvent struct {
+	RecipientID uuid.UUID     `json:"recipient_id"`
+	ActorID     uuid.UUID     `json:"actor_id"`
+	Type        string        `json:"type"`
+	ChirpID     uuid.NullUUID `json:"chirp_id"`
+}
+
+// groupKey decides which events share a notification: all new followers
+// together, and everyone who rechirped or quoted the same chirp together.
+func (e notificationEvent) groupKey() string {
+	if e.Type == notificationFollow {
+		return e.Type
+	}
+	return e.Type + ":" + e.ChirpID.UUID.String()
+}
+
+type NotificationActor struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+type Notification struct {
+	ID         uuid.UUID           `json:"id"`
+	Type       string              `json:"type"`
+	ChirpID    *uuid.UUID          `json:"chirp_id,omitempty"`
+	Actors     []NotificationActor `json:"actors"`
+	ActorCount int                 `json:"actor_count"`
+	Summary    string              `json:"summary"`
+	Read       bool                `json:"read"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+// notificationSummary reads like "alice, bob and 3 others rechirped your
+// chirp". actors holds the most recent few of count.
+func notificationSummary(kind string, actors []NotificationActor, count int) string {
+	names := ""
+	switch {
+	case count == 1:
+		names = actors[0].Username
+	case count == 2 && len(actors) >= 2:
+		names = actors[0].Username + " and " + actors[1].Username
+	case count == 2:
+		names = actors[0].Username + " and 1 other"
+	default:
+		names = fmt.Sprintf("%s and %d others", actors[0].Username, count-1)
+	}
+	return names + " " + notificationVerbs[kind]
+}
+
+// notify records an event unless the recipient turned that type off, or
+// wouldn't see the actor's chirps: blocked either way, muted, or banned.
+func (cfg *apiConfig) notify(ctx context.Context, e notificationEvent) error {
+	if e.RecipientID == e.ActorID {
+		return nil
+	}
+
+	enabled, err := cfg.db.NotificationEnabled(ctx, database.NotificationEnabledParams{
+		UserID: e.RecipientID,
+		Type:   e.Type,
+	})
+	if err != nil || !enabled {
+		return err
+	}
+	hidden, err := cfg.db.GetHiddenAuthors(ctx, database.GetHiddenAuthorsParams{
+		Ids:      []uuid.UUID{e.ActorID},
+		ViewerID: e.RecipientID,
+	})
+	if err != nil || len(hidden) > 0 {
+		return err
+	}
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	notification, err := q.UpsertNotification(ctx, database.UpsertNotificationParams{
+		UserID:   e.RecipientID,
+		Type:     e.Type,
+		ChirpID:  e.ChirpID,
+		GroupKey: e.groupKey(),
+	})
+	if err != nil {
+		return err
+	}
+	err = q.AddNotificationActor(ctx, database.AddNotificationActorParams{
+		NotificationID: notification.ID,
+		ActorID:        e.ActorID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	cfg.publishNotification(ctx, e.ActorID, notification)
+	return nil
+}
+
+// publishNotification pushes a new or updated notification to the
+// recipient's live connections.
+func (cfg *apiConfig) publishNotification(ctx context.Context, actorID uuid.UUID, row database.Notification) {
+	notifications, err := cfg.expandNotifications(ctx, row.UserID, []database.Notification{row})
+	if err != nil {
+		log.Printf("Couldn't render notification %s for streaming: %v", row.ID, err)
+		return
+	}
+	if len(notifications) == 0 {
+		return
+	}
+	cfg.publishEvent(ctx, stream.Event{
+		Type:      eventNotification,
+		Author:    actorID,
+		Recipient: row.UserID,
+	}, notifications[0])
+}
+
+func (cfg *apiConfig) jobNotify(ctx context.Context, payload json.RawMessage) error {
+	var e notificationEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return err
+	}
+	return cfg.notify(ctx, e)
+}
+
+// jobNotifyChirp sends the notifications a newly visible chirp causes: to
+// the author of the chirp it rechirps or quotes, and to everyone it
+// mentions.
+func (cfg *apiConfig) jobNotifyChirp(ctx context.Context, payload json.RawMessage) error {
+	var p fanOutPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+
+	chirp, err := cfg.db.GetChirpsByID(ctx, p.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if id, ok := referencedChirpID(chirp); ok {
+		original, err := cfg.db.GetChirpsByID(ctx, id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			kind := notificationQuote
+			if chirp.RechirpOf.Valid {
+				kind = notificationRechirp
+			}
+			err := cfg.notify(ctx, notificationEvent{
+				RecipientID: original.UserID,
+				ActorID:     chirp.UserID,
+				Type:        kind,
+				ChirpID:     uuid.NullUUID{UUID: original.ID, Valid: true},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// A rechirp repeats its original's body; the people it mentions were
+	// notified when the original was posted.
+	if chirp.RechirpOf.Valid {
+		return nil
+	}
+	mentions, err := cfg.db.GetMentionsForChirps(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		return err
+	}
+	for _, mention := range mentions {
+		err := cfg.notify(ctx, notificationEvent{
+			RecipientID: mention.UserID,
+			ActorID:     chirp.UserID,
+			Type:        notificationMention,
+			ChirpID:     uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handlerNotifications lists the caller's notifications, newest activity
+// first, with the number still unread.
+func (cfg *apiConfig) handlerNotifications(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		UnreadCount   int64          `json:"unread_count"`
+		Notifications []Notification `json:"notifications"`
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID: userID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve notifications", err)
+		return
+	}
+
+	notifications, err := cfg.expandNotifications(r.Context(), userID, rows)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve notifications", err)
+		return
+	}
+
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve notifications", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{UnreadCount: unread, Notifications: notifications})
+}
+
+// expandNotifications attaches actors to each notification. Actors the
+// viewer has since blocked or muted are left out, along with any
+// notification that has no one left.
+func (cfg *apiConfig) expandNotifications(ctx context.Context, viewerID uuid.UUID, rows []database.Notification) ([]Notification, error) {
+	notifications := []Notification{}
+	if len(rows) == 0 {
+		return notifications, nil
+	}
+
+	ids := []uuid.UUID{}
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	actorRows, err := cfg.db.GetNotificationActors(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	actorIDs := []uuid.UUID{}
+	for _, a := range actorRows {
+		actorIDs = append(actorIDs, a.ActorID)
+	}
+	hiddenIDs, err := cfg.db.GetHiddenAuthors(ctx, database.GetHiddenAuthorsParams{
+		Ids:      actorIDs,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	hidden := map[uuid.UUID]bool{}
+	for _, id := range hiddenIDs {
+		hidden[id] = true
+	}
+
+	actors := map[uuid.UUID][]NotificationActor{}
+	for _, a := range actorRows {
+		if !hidden[a.ActorID] {
+			actors[a.NotificationID] = append(actors[a.NotificationID], NotificationActor{ID: a.ActorID, Username: a.Username})
+		}
+	}
+
+	for _, row := range rows {
+		all := actors[row.ID]
+		if len(all) == 0 {
+			continue
+		}
+		shown := all[:min(len(all), notificationActorsShown)]
+		n := Notification{
+			ID:         row.ID,
+			Type:       row.Type,
+			Actors:     shown,
+			ActorCount: len(all),
+			Summary:    notificationSummary(row.Type, shown, len(all)),
+			Read:       row.ReadAt.Valid,
+			CreatedAt:  row.CreatedAt,
+			UpdatedAt:  row.UpdatedAt,
+		}
+		if row.ChirpID.Valid {
+			n.ChirpID = &row.ChirpID.UUID
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+func (cfg *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	notificationID, err := uuid.Parse(r.PathValue("notificationID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid notification ID", err)
+		return
+	}
+
+	// Marking an already read notification is a no-op, not an error.
+	_, err = cfg.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update notification", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	if _, err := cfg.db.MarkAllNotificationsRead(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update notifications", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) notificationPreferences(ctx context.Context, userID uuid.UUID) (map[string]bool, error) {
+	prefs := map[string]bool{}
+	for _, kind := range notificationTypes {
+		prefs[kind] = true
+	}
+	rows, err := cfg.db.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if _, ok := prefs[row.Type]; ok {
+			prefs[row.Type] = row.Enabled
+		}
+	}
+	return prefs, nil
+}
+
+func (cfg *apiConfig) handlerNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve preferences", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+// handlerUpdateNotificationPreferences takes a map of type to enabled. Types
+// that aren't mentioned keep their current setting.
+func (cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	params := map[string]bool{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	for kind := range params {
+		if _, ok := notificationVerbs[kind]; !ok {
+			respondWithError(w, http.StatusBadRequest, "Unknown notification type "+kind, nil)
+			return
+		}
+	}
+
+	for kind, enabled := range params {
+		err := cfg.db.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+			UserID:  userID,
+			Type:    kind,
+			Enabled: enabled,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update preferences", err)
+			return
+		}
+	}
+
+	prefs, err := cfg.notificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve preferences", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, prefs)
+}
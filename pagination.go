@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// cursorPage reads the "before" (RFC 3339) and "limit" query parameters used
+// by the newest-first feeds. Clients page by passing the created_at of the
+// last chirp they received as the next "before".
+func cursorPage(r *http.Request) (time.Time, int32, error) {
+	before := time.Now().UTC()
+	if s := r.URL.Query().Get("before"); s != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, 0, errors.New("before must be an RFC 3339 timestamp")
+		}
+		before = parsed
+	}
+
+	limit, err := pageLimit(r)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return before, limit, nil
+}
+
+// offsetPage reads the "limit" and "offset" query parameters used by the
+// account lists.
+func offsetPage(r *http.Request) (int32, int32, error) {
+	limit, err := pageLimit(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	offset := 0
+	if s := r.URL.Query().Get("offset"); s != "" {
+		offset, err = strconv.Atoi(s)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, int32(offset), nil
+}
+
+func pageLimit(r *http.Request) (int32, error) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return int32(limit), nil
+}
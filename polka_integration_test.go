@@ -0,0 +1,323 @@
+//go:build integration
+
+// Integration tests run Chirpy's Polka webhook against the fake Polka client
+// and a throwaway Postgres database. They need CHIRPY_TEST_DB_URL, a URL for
+// a role that may create databases:
+//
+//	CHIRPY_TEST_DB_URL=postgres://postgres@localhost:5432/postgres?sslmode=disable \
+//		go test -tags integration -run Polka .
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/auth"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/entitlements"
+	"workspace/github.com/Benjysparks/chirpy/internal/fakepolka"
+)
+
+const testPolkaSecret = "whsec_integration"
+
+type polkaHarness struct {
+	cfg    *apiConfig
+	polka  *fakepolka.Client
+	server *httptest.Server
+}
+
+// newPolkaHarness creates a fresh database, migrates it and serves the
+// Polka and subscription endpoints from it.
+func newPolkaHarness(t *testing.T) *polkaHarness {
+	t.Helper()
+	db := newTestDB(t)
+
+	cfg := &apiConfig{
+		db:          database.New(db),
+		dbConn:      db,
+		JwtSecret:   "integration",
+		polkaSecret: testPolkaSecret,
+		limitPolicy: entitlements.DefaultPolicy(),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerPolkaWebhook)
+	mux.HandleFunc("GET /api/users/me/subscription", cfg.handlerMySubscription)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return &polkaHarness{
+		cfg:    cfg,
+		server: server,
+		polka: &fakepolka.Client{
+			URL:    server.URL + "/api/polka/webhooks",
+			Secret: testPolkaSecret,
+		},
+	}
+}
+
+func (h *polkaHarness) newUser(t *testing.T) uuid.UUID {
+	t.Helper()
+	name := "user" + strings.ReplaceAll(uuid.NewString()[:8], "-", "")
+	user, err := h.cfg.db.CreateUser(context.Background(), database.CreateUserParams{
+		Email:    name + "@example.com",
+		Username: name,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func (h *polkaHarness) send(t *testing.T, e fakepolka.Event) int {
+	t.Helper()
+	resp, err := h.polka.Send(context.Background(), e.Body())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+type subscriptionState struct {
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	Entitlements     planLimits `json:"entitlements"`
+	isChirpyRed      bool
+}
+
+// state reads the subscription through the API, as a client would, and the
+// is_chirpy_red flag that user responses still carry.
+func (h *polkaHarness) state(t *testing.T, userID uuid.UUID) subscriptionState {
+	t.Helper()
+	token, err := auth.MakeJWT(userID, h.cfg.JwtSecret, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, h.server.URL+"/api/users/me/subscription", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("subscription: status %d", resp.StatusCode)
+	}
+
+	var s subscriptionState
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	user, err := h.cfg.db.GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.isChirpyRed = user.IsChirpyRed.Bool
+	return s
+}
+
+func (h *polkaHarness) logStatuses(t *testing.T) map[string]int {
+	t.Helper()
+	rows, err := h.cfg.db.GetPolkaWebhookLog(context.Background(), database.GetPolkaWebhookLogParams{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for _, row := range rows {
+		counts[row.Status]++
+	}
+	return counts
+}
+
+func TestPolkaSubscriptionLifecycle(t *testing.T) {
+	h := newPolkaHarness(t)
+	userID := h.newUser(t)
+	red := entitlements.DefaultPolicy().For(entitlements.PlanRed)
+
+	if s := h.state(t, userID); s.Plan != entitlements.PlanFree || s.isChirpyRed {
+		t.Fatalf("expected a new user to be free, got %+v", s)
+	}
+
+	end := time.Now().UTC().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	if code := h.send(t, fakepolka.NewEvent(fakepolka.UserUpgraded, userID).WithPeriodEnd(end)); code != http.StatusNoContent {
+		t.Fatalf("upgrade: status %d", code)
+	}
+	s := h.state(t, userID)
+	if s.Status != entitlements.StatusActive || !s.isChirpyRed || !s.CurrentPeriodEnd.Equal(end) {
+		t.Fatalf("expected an active subscription until %v, got %+v", end, s)
+	}
+	if s.Entitlements.MaxChirpLength != red.MaxChirpLength {
+		t.Errorf("expected Red limits, got %+v", s.Entitlements)
+	}
+
+	renewed := end.Add(30 * 24 * time.Hour)
+	h.send(t, fakepolka.NewEvent(fakepolka.SubscriptionRenewed, userID).WithPeriodEnd(renewed))
+	if s := h.state(t, userID); !s.CurrentPeriodEnd.Equal(renewed) {
+		t.Errorf("expected the renewal to extend the period, got %v", s.CurrentPeriodEnd)
+	}
+
+	h.send(t, fakepolka.NewEvent(fakepolka.PaymentFailed, userID))
+	if s := h.state(t, userID); s.Status != entitlements.StatusPastDue || s.Plan != entitlements.PlanRed {
+		t.Errorf("expected past due, got %+v", s)
+	}
+
+	h.send(t, fakepolka.NewEvent(fakepolka.UserDowngraded, userID))
+	s = h.state(t, userID)
+	if s.Status != entitlements.StatusCanceled || !s.isChirpyRed || s.Entitlements.MaxChirpLength != red.MaxChirpLength {
+		t.Errorf("expected Red to last until the period ends, got %+v", s)
+	}
+
+	// Let the paid period run out, then run the expiry job.
+	if _, err := h.cfg.dbConn.Exec("UPDATE subscriptions SET current_period_end = NOW() - INTERVAL '1 minute' WHERE user_id = $1", userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.cfg.jobExpireSubscriptions(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	s = h.state(t, userID)
+	if s.Status != entitlements.StatusExpired || s.isChirpyRed {
+		t.Errorf("expected the subscription to expire, got %+v", s)
+	}
+	if s.Entitlements.MaxChirpLength != entitlements.DefaultPolicy().For(entitlements.PlanFree).MaxChirpLength {
+		t.Errorf("expected free limits, got %+v", s.Entitlements)
+	}
+}
+
+func TestPolkaRetriesApplyOnce(t *testing.T) {
+	h := newPolkaHarness(t)
+	userID := h.newUser(t)
+
+	// Without an explicit period end each renewal adds a period, so a
+	// renewal applied twice would show.
+	h.send(t, fakepolka.NewEvent(fakepolka.UserUpgraded, userID))
+	before := h.state(t, userID).CurrentPeriodEnd
+
+	renewal := fakepolka.NewEvent(fakepolka.SubscriptionRenewed, userID)
+	for _, result := range h.polka.Replay(context.Background(), [][]byte{renewal.Body()}, fakepolka.Options{Duplicates: 3}) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+
+	after := h.state(t, userID).CurrentPeriodEnd
+	if got := after.Sub(*before); got != entitlements.Period {
+		t.Errorf("expected one period to be added, got %v", got)
+	}
+	if counts := h.logStatuses(t); counts[polkaProcessed] != 2 || counts[polkaDuplicate] != 3 {
+		t.Errorf("unexpected log statuses %v", counts)
+	}
+}
+
+func TestPolkaRenewalsWithoutIDs(t *testing.T) {
+	h := newPolkaHarness(t)
+	userID := h.newUser(t)
+
+	h.send(t, fakepolka.NewEvent(fakepolka.UserUpgraded, userID))
+	before := h.state(t, userID).CurrentPeriodEnd
+
+	// Older Polka versions send the same bytes for every renewal. Renewals
+	// signed at different times must both count, while a retry of the same
+	// signed request must not.
+	renewal := fakepolka.NewEvent(fakepolka.SubscriptionRenewed, userID)
+	renewal.ID = ""
+	body := renewal.Body()
+	signedAt := time.Now()
+	for _, offset := range []time.Duration{0, 0, time.Minute} {
+		client := &fakepolka.Client{URL: h.polka.URL, Secret: testPolkaSecret, Now: func() time.Time { return signedAt.Add(offset) }}
+		resp, err := client.Send(context.Background(), body)
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("renewal: got %+v %v", resp, err)
+		}
+	}
+
+	after := h.state(t, userID).CurrentPeriodEnd
+	if got := after.Sub(*before); got != 2*entitlements.Period {
+		t.Errorf("expected two periods to be added, got %v", got)
+	}
+	if counts := h.logStatuses(t); counts[polkaProcessed] != 3 || counts[polkaDuplicate] != 1 {
+		t.Errorf("unexpected log statuses %v", counts)
+	}
+}
+
+func TestPolkaUnsignedEventsWithoutIDs(t *testing.T) {
+	h := newPolkaHarness(t)
+	h.cfg.polkaSecret = ""
+	h.cfg.polkaKey = "integration-key"
+	h.polka = &fakepolka.Client{URL: h.polka.URL, APIKey: h.cfg.polkaKey}
+	userID := h.newUser(t)
+
+	h.send(t, fakepolka.NewEvent(fakepolka.UserUpgraded, userID))
+	before := h.state(t, userID).CurrentPeriodEnd
+
+	// With only an API key there is nothing to tell a replay from a new
+	// renewal, so both are applied and logged as unverified.
+	renewal := fakepolka.NewEvent(fakepolka.SubscriptionRenewed, userID)
+	renewal.ID = ""
+	for i := 0; i < 2; i++ {
+		if code := h.send(t, renewal); code != http.StatusNoContent {
+			t.Fatalf("renewal: status %d", code)
+		}
+	}
+
+	after := h.state(t, userID).CurrentPeriodEnd
+	if got := after.Sub(*before); got != 2*entitlements.Period {
+		t.Errorf("expected two periods to be added, got %v", got)
+	}
+	if counts := h.logStatuses(t); counts[polkaUnverified] != 2 {
+		t.Errorf("unexpected log statuses %v", counts)
+	}
+}
+
+func TestPolkaOutOfOrderDelivery(t *testing.T) {
+	h := newPolkaHarness(t)
+	userID := h.newUser(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := now.Add(30 * 24 * time.Hour)
+	second := now.Add(60 * 24 * time.Hour)
+	events := [][]byte{
+		fakepolka.NewEvent(fakepolka.SubscriptionRenewed, userID).WithPeriodEnd(second).Body(),
+		fakepolka.NewEvent(fakepolka.UserUpgraded, userID).WithPeriodEnd(first).Body(),
+	}
+	for _, result := range h.polka.Replay(context.Background(), events, fakepolka.Options{}) {
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+	}
+
+	if s := h.state(t, userID); s.Status != entitlements.StatusActive || !s.CurrentPeriodEnd.Equal(second) {
+		t.Errorf("expected the late upgrade not to shorten the period, got %+v", s)
+	}
+}
+
+func TestPolkaRejectsBadRequests(t *testing.T) {
+	h := newPolkaHarness(t)
+	userID := h.newUser(t)
+
+	forged := &fakepolka.Client{URL: h.polka.URL, Secret: "whsec_wrong"}
+	if resp, err := forged.Send(context.Background(), fakepolka.NewEvent(fakepolka.UserUpgraded, userID).Body()); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a bad signature to be refused, got %+v %v", resp, err)
+	}
+
+	stale := &fakepolka.Client{URL: h.polka.URL, Secret: testPolkaSecret, Now: func() time.Time { return time.Now().Add(-time.Hour) }}
+	if resp, err := stale.Send(context.Background(), fakepolka.NewEvent(fakepolka.UserUpgraded, userID).Body()); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a stale signature to be refused, got %+v %v", resp, err)
+	}
+
+	if code := h.send(t, fakepolka.NewEvent(fakepolka.UserUpgraded, uuid.New())); code != http.StatusNotFound {
+		t.Errorf("expected an unknown user to be 404, got %d", code)
+	}
+
+	if s := h.state(t, userID); s.Plan != entitlements.PlanFree {
+		t.Errorf("expected no change, got %+v", s)
+	}
+	if counts := h.logStatuses(t); counts[polkaRejected] != 2 || counts[polkaFailed] != 1 {
+		t.Errorf("unexpected log statuses %v", counts)
+	}
+}
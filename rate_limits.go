@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"workspace/github.com/Benjysparks/chirpy/internal/auth"
+	"workspace/github.com/Benjysparks/chirpy/internal/entitlements"
+	"workspace/github.com/Benjysparks/chirpy/internal/ratelimit"
+)
+
+// Rate limit tiers. Requests with a valid access token are limited per user,
+// Polka webhooks carrying our API key per key, and everything else per
+// client IP.
+const (
+	tierAnonymous = "anonymous"
+	tierUser      = "user"
+	tierRed       = "red"
+	tierAPIKey    = "api_key"
+)
+
+const (
+	jobPruneRateLimits = "ratelimit.prune"
+	// rateLimitRetention must be longer than the longest Per below, so a
+	// bucket is never pruned while it is still refilling.
+	rateLimitRetention = 24 * time.Hour
+)
+
+func perMinute(n int) ratelimit.Limit { return ratelimit.Limit{Burst: n, Per: time.Minute} }
+func perHour(n int) ratelimit.Limit   { return ratelimit.Limit{Burst: n, Per: time.Hour} }
+
+// Per-route limits. Default applies to any tier that isn't listed.
+var (
+	limitCreateChirp = ratelimit.Rule{
+		Name:    "chirps.create",
+		Default: perMinute(10),
+		Tiers:   map[string]ratelimit.Limit{tierRed: perMinute(60)},
+	}
+	limitCreateUser = ratelimit.Rule{
+		Name:    "users.create",
+		Default: perHour(5),
+	}
+	limitLogin = ratelimit.Rule{
+		Name:    "auth.login",
+		Default: perMinute(10),
+	}
+	limitRefresh = ratelimit.Rule{
+		Name:    "auth.refresh",
+		Default: perMinute(30),
+	}
+	limitReport = ratelimit.Rule{
+		Name:    "reports.create",
+		Default: perHour(20),
+		Tiers:   map[string]ratelimit.Limit{tierRed: perHour(40)},
+	}
+	limitFollow = ratelimit.Rule{
+		Name:    "follows",
+		Default: perHour(200),
+		Tiers:   map[string]ratelimit.Limit{tierRed: perHour(1000)},
+	}
+	limitUploadMedia = ratelimit.Rule{
+		Name:    "media.upload",
+		Default: perHour(60),
+		Tiers:   map[string]ratelimit.Limit{tierRed: perHour(240)},
+	}
+	limitStartConversation = ratelimit.Rule{
+		Name:    "conversations.create",
+		Default: perHour(30),
+		Tiers:   map[string]ratelimit.Limit{tierRed: perHour(120)},
+	}
+	limitSendMessage = ratelimit.Rule{
+		Name:    "messages.send",
+		Default: perMinute(30),
+		Tiers:   map[string]ratelimit.Limit{tierRed: perMinute(120)},
+	}
+	limitPolkaWebhook = ratelimit.Rule{
+		Name:    "polka.webhooks",
+		Default: perMinute(30),
+		Tiers:   map[string]ratelimit.Limit{tierAPIKey: perMinute(600)},
+	}
+)
+
+// newRateLimitStore picks where buckets live. "postgres" shares limits
+// across every instance; the default keeps them in memory.
+func (cfg *apiConfig) newRateLimitStore(kind string) ratelimit.Store {
+	if kind == "postgres" {
+		cfg.rateLimitBuckets = ratelimit.NewPostgresStore(cfg.dbConn)
+		return cfg.rateLimitBuckets
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+// rateLimitPrincipal decides who a request counts against. Looking up the
+// user's entitlements costs a query, but it's the only way to know whether
+// they are a Chirpy Red member.
+func (cfg *apiConfig) rateLimitPrincipal(r *http.Request) ratelimit.Principal {
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		if userID, err := auth.ValidateJWT(token, cfg.JwtSecret); err == nil {
+			tier := tierUser
+			if limits, err := cfg.userEntitlements(r.Context(), userID); err == nil && limits.Plan == entitlements.PlanRed {
+				tier = tierRed
+			}
+			return ratelimit.Principal{Key: "user:" + userID.String(), Tier: tier}
+		}
+	}
+	// Only a key that matches is trusted; otherwise a client could make up
+	// a fresh key for every request and never hit an IP limit.
+	if key, err := auth.GetAPIKey(r.Header); err == nil && r.URL.Path == polkaWebhookPath &&
+		cfg.polkaKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(cfg.polkaKey)) == 1 {
+		sum := sha256.Sum256([]byte(key))
+		return ratelimit.Principal{Key: "key:" + hex.EncodeToString(sum[:8]), Tier: tierAPIKey}
+	}
+	return ratelimit.Principal{Key: "ip:" + ratelimit.ClientIP(r, cfg.trustProxy), Tier: tierAnonymous}
+}
+
+// jobPruneRateLimits deletes Postgres buckets that have long since refilled.
+func (cfg *apiConfig) jobPruneRateLimits(ctx context.Context, _ json.RawMessage) error {
+	if cfg.rateLimitBuckets == nil {
+		return nil
+	}
+	return cfg.rateLimitBuckets.Prune(ctx, time.Now().UTC().Add(-rateLimitRetention))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+// originalChirpID follows a rechirp back to the chirp it reposts so that
+// rechirps and quotes always point at original content.
+func originalChirpID(chirp database.Chirp) uuid.UUID {
+	if chirp.RechirpOf.Valid {
+		return chirp.RechirpOf.UUID
+	}
+	return chirp.ID
+}
+
+func (cfg *apiConfig) handlerRechirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	target, err := cfg.db.GetChirpsByID(r.Context(), chirpID)
+	if err == nil && target.Status != chirpStatusPublished {
+		err = sql.ErrNoRows
+	}
+	if err == nil {
+		var blocked bool
+		blocked, err = cfg.blockedEitherWay(r.Context(), userID, target.UserID)
+		if err == nil && blocked {
+			err = sql.ErrNoRows
+		}
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+
+	rechirp, err := cfg.db.CreateRechirp(r.Context(), database.CreateRechirpParams{
+		UserID:    userID,
+		RechirpOf: uuid.NullUUID{UUID: originalChirpID(target), Valid: true},
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Chirp already rechirped", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	cfg.afterChirpCreated(r.Context(), rechirp)
+
+	chirps, err := cfg.expandChirps(r.Context(), userID, []database.Chirp{rechirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirps[0])
+}
+
+func (cfg *apiConfig) handlerUndoRechirp(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	// Rechirps of a rechirp point at the original, so undo has to follow
+	// the same path handlerRechirp took.
+	target, err := cfg.db.GetChirpsByID(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp has not been rechirped", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't undo rechirp", err)
+		return
+	}
+	originalID := originalChirpID(target)
+
+	deleted, err := cfg.db.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+		UserID:    userID,
+		RechirpOf: uuid.NullUUID{UUID: originalID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't undo rechirp", err)
+		return
+	}
+	if len(deleted) == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp has not been rechirped", nil)
+		return
+	}
+	for _, id := range deleted {
+		cfg.publishChirpDeleted(r.Context(), database.Chirp{
+			ID:        id,
+			UserID:    userID,
+			RechirpOf: uuid.NullUUID{UUID: originalID, Valid: true},
+		})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
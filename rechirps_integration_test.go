@@ -0,0 +1,97 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/auth"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/jobs"
+	"workspace/github.com/Benjysparks/chirpy/internal/stream"
+)
+
+func TestUndoRechirpThroughARechirp(t *testing.T) {
+	db := newTestDB(t)
+	queries := database.New(db)
+	hub := stream.NewHub(stream.DefaultBacklog)
+	cfg := &apiConfig{
+		db:        queries,
+		dbConn:    db,
+		JwtSecret: "integration",
+		jobs:      jobs.NewQueue(queries),
+		hub:       hub,
+		broker:    hub,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", cfg.handlerRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", cfg.handlerUndoRechirp)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+	newUser := func() uuid.UUID {
+		name := "user" + strings.ReplaceAll(uuid.NewString()[:8], "-", "")
+		user, err := queries.CreateUser(ctx, database.CreateUserParams{Email: name + "@example.com", Username: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.ID
+	}
+	call := func(method string, chirpID, userID uuid.UUID) *http.Response {
+		token, err := auth.MakeJWT(userID, cfg.JwtSecret, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest(method, server.URL+"/api/chirps/"+chirpID.String()+"/rechirp", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	author, first, second := newUser(), newUser(), newUser()
+	original, err := queries.NewChirp(ctx, database.NewChirpParams{
+		Body:   "an original chirp",
+		UserID: author,
+		Status: chirpStatusPublished,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := call(http.MethodPost, original.ID, first)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("rechirp: status %d", resp.StatusCode)
+	}
+	var rechirp Chirp
+	if err := json.NewDecoder(resp.Body).Decode(&rechirp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second user rechirps the first user's rechirp, which is stored
+	// as a rechirp of the original, then undoes it by the ID they used.
+	if resp := call(http.MethodPost, rechirp.ID, second); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("rechirp of a rechirp: status %d", resp.StatusCode)
+	}
+	if resp := call(http.MethodDelete, rechirp.ID, second); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("undo through the rechirp: status %d", resp.StatusCode)
+	}
+	if resp := call(http.MethodDelete, rechirp.ID, second); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("second undo: status %d", resp.StatusCode)
+	}
+
+	if resp := call(http.MethodDelete, original.ID, first); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("undo through the original: status %d", resp.StatusCode)
+	}
+}
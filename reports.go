@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+const (
+	reportStatusOpen     = "open"
+	reportStatusClaimed  = "claimed"
+	reportStatusResolved = "resolved"
+
+	decisionDismiss     = "dismiss"
+	decisionHideChirp   = "hide_chirp"
+	decisionSuspendUser = "suspend_user"
+
+	maxReportDetailsLength = 1000
+	defaultSuspensionDays  = 7
+	maxSuspensionDays      = 365
+)
+
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"misinformation": true,
+	"other":          true,
+}
+
+type Report struct {
+	ID             uuid.UUID  `json:"id"`
+	ReporterID     uuid.UUID  `json:"reporter_id"`
+	ReportedUserID uuid.UUID  `json:"reported_user_id"`
+	ChirpID        *uuid.UUID `json:"chirp_id,omitempty"`
+	Reason         string     `json:"reason"`
+	Details        string     `json:"details"`
+	Status         string     `json:"status"`
+	ClaimedBy      *uuid.UUID `json:"claimed_by,omitempty"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func reportFromDB(r database.Report) Report {
+	report := Report{
+		ID:             r.ID,
+		ReporterID:     r.ReporterID,
+		ReportedUserID: r.ReportedUserID,
+		Reason:         r.Reason,
+		Details:        r.Details,
+		Status:         r.Status,
+		CreatedAt:      r.CreatedAt,
+		UpdatedAt:      r.UpdatedAt,
+	}
+	if r.ChirpID.Valid {
+		report.ChirpID = &r.ChirpID.UUID
+	}
+	if r.ClaimedBy.Valid {
+		report.ClaimedBy = &r.ClaimedBy.UUID
+	}
+	if r.ClaimedAt.Valid {
+		report.ClaimedAt = &r.ClaimedAt.Time
+	}
+	if r.ResolvedAt.Valid {
+		report.ResolvedAt = &r.ResolvedAt.Time
+	}
+	return report
+}
+
+type ModerationDecision struct {
+	ID            uuid.UUID  `json:"id"`
+	ReportID      uuid.UUID  `json:"report_id"`
+	ModeratorID   uuid.UUID  `json:"moderator_id"`
+	SubjectUserID uuid.UUID  `json:"subject_user_id"`
+	ChirpID       *uuid.UUID `json:"chirp_id,omitempty"`
+	Action        string     `json:"action"`
+	Note          string     `json:"note"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func decisionFromDB(d database.ModerationDecision) ModerationDecision {
+	decision := ModerationDecision{
+		ID:            d.ID,
+		ReportID:      d.ReportID,
+		ModeratorID:   d.ModeratorID,
+		SubjectUserID: d.SubjectUserID,
+		Action:        d.Action,
+		Note:          d.Note,
+		CreatedAt:     d.CreatedAt,
+	}
+	if d.ChirpID.Valid {
+		decision.ChirpID = &d.ChirpID.UUID
+	}
+	return decision
+}
+
+type reportParameters struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+func decodeReport(r *http.Request) (reportParameters, error) {
+	params := reportParameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return params, errors.New("Couldn't decode parameters")
+	}
+	if !reportReasons[params.Reason] {
+		return params, fmt.Errorf("Unknown reason %q", params.Reason)
+	}
+	if len(params.Details) > maxReportDetailsLength {
+		return params, fmt.Errorf("Details can be at most %d characters", maxReportDetailsLength)
+	}
+	return params, nil
+}
+
+func (cfg *apiConfig) handlerReportChirp(w http.ResponseWriter, r *http.Request) {
+	reporterID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	params, err := decodeReport(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpsByID(r.Context(), chirpID)
+	if err != nil || chirp.Status != chirpStatusPublished {
+		respondWithError(w, http.StatusNotFound, "Couldn't find chirp", err)
+		return
+	}
+	if chirp.UserID == reporterID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp", nil)
+		return
+	}
+
+	report, err := cfg.db.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID:     reporterID,
+		ReportedUserID: chirp.UserID,
+		ChirpID:        uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Reason:         params.Reason,
+		Details:        params.Details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+func (cfg *apiConfig) handlerReportUser(w http.ResponseWriter, r *http.Request) {
+	reporterID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+	if userID == reporterID {
+		respondWithError(w, http.StatusBadRequest, "You can't report yourself", nil)
+		return
+	}
+
+	params, err := decodeReport(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if _, err := cfg.db.GetUserByID(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	report, err := cfg.db.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID:     reporterID,
+		ReportedUserID: userID,
+		Reason:         params.Reason,
+		Details:        params.Details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reportFromDB(report))
+}
+
+func (cfg *apiConfig) handlerListReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = reportStatusOpen
+	}
+	if status != reportStatusOpen && status != reportStatusClaimed && status != reportStatusResolved {
+		respondWithError(w, http.StatusBadRequest, "status must be open, claimed or resolved", nil)
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbReports, err := cfg.db.GetReportsByStatus(r.Context(), database.GetReportsByStatusParams{
+		Status: status,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve reports", err)
+		return
+	}
+
+	reports := []Report{}
+	for _, report := range dbReports {
+		reports = append(reports, reportFromDB(report))
+	}
+	respondWithJSON(w, http.StatusOK, reports)
+}
+
+func (cfg *apiConfig) handlerGetReport(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Report
+		Decisions []ModerationDecision `json:"decisions"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	report, err := cfg.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find report", err)
+		return
+	}
+
+	dbDecisions, err := cfg.db.GetModerationDecisionsForReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve decisions", err)
+		return
+	}
+
+	decisions := []ModerationDecision{}
+	for _, d := range dbDecisions {
+		decisions = append(decisions, decisionFromDB(d))
+	}
+	respondWithJSON(w, http.StatusOK, response{Report: reportFromDB(report), Decisions: decisions})
+}
+
+// handlerClaimReport assigns an open report to the calling moderator so two
+// moderators don't work the same report. Claiming a report you already hold
+// is a no-op.
+func (cfg *apiConfig) handlerClaimReport(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := cfg.requireRole(w, r, roleModerator)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	report, err := cfg.db.ClaimReport(r.Context(), database.ClaimReportParams{
+		ID:        reportID,
+		ClaimedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := cfg.db.GetReport(r.Context(), reportID); err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find report", err)
+			return
+		}
+		respondWithError(w, http.StatusConflict, "Report is already claimed or resolved", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't claim report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+// handlerResolveReport closes a report the caller has claimed, records the
+// decision and applies it.
+func (cfg *apiConfig) handlerResolveReport(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action      string `json:"action"`
+		Note        string `json:"note"`
+		SuspendDays int    `json:"suspend_days"`
+	}
+
+	moderator, ok := cfg.requireRole(w, r, roleModerator)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	switch params.Action {
+	case decisionDismiss, decisionHideChirp, decisionSuspendUser:
+	default:
+		respondWithError(w, http.StatusBadRequest, "action must be dismiss, hide_chirp or suspend_user", nil)
+		return
+	}
+	if params.SuspendDays == 0 {
+		params.SuspendDays = defaultSuspensionDays
+	}
+	if params.SuspendDays < 0 || params.SuspendDays > maxSuspensionDays {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("suspend_days must be between 1 and %d", maxSuspensionDays), nil)
+		return
+	}
+
+	report, err := cfg.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find report", err)
+		return
+	}
+	if params.Action == decisionHideChirp && !report.ChirpID.Valid {
+		respondWithError(w, http.StatusBadRequest, "Report isn't about a chirp", nil)
+		return
+	}
+
+	decision, err := cfg.resolveReport(r.Context(), moderator.ID, report, params.Action, params.Note, time.Duration(params.SuspendDays)*24*time.Hour)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Claim the report before resolving it", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't resolve report", err)
+		return
+	}
+	if params.Action == decisionHideChirp {
+		if chirp, err := cfg.db.GetChirpsByID(r.Context(), report.ChirpID.UUID); err == nil {
+			cfg.publishChirpDeleted(r.Context(), chirp)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, decisionFromDB(decision))
+}
+
+// resolveReport closes the report, writes the decision and carries out its
+// action in one transaction. It returns sql.ErrNoRows if the moderator
+// doesn't hold a claim on the report.
+func (cfg *apiConfig) resolveReport(ctx context.Context, moderatorID uuid.UUID, report database.Report, action, note string, suspendFor time.Duration) (database.ModerationDecision, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.ModerationDecision{}, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	_, err = q.ResolveReport(ctx, database.ResolveReportParams{
+		ID:        report.ID,
+		ClaimedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+	})
+	if err != nil {
+		return database.ModerationDecision{}, err
+	}
+
+	decision, err := q.RecordModerationDecision(ctx, database.RecordModerationDecisionParams{
+		ReportID:      report.ID,
+		ModeratorID:   moderatorID,
+		SubjectUserID: report.ReportedUserID,
+		ChirpID:       report.ChirpID,
+		Action:        action,
+		Note:          note,
+	})
+	if err != nil {
+		return database.ModerationDecision{}, err
+	}
+
+	switch action {
+	case decisionHideChirp:
+		err = q.HideChirp(ctx, report.ChirpID.UUID)
+	case decisionSuspendUser:
+		err = suspendReportedUser(ctx, q, moderatorID, report.ReportedUserID, time.Now().UTC().Add(suspendFor), note)
+	}
+	if err != nil {
+		return database.ModerationDecision{}, err
+	}
+
+	return decision, tx.Commit()
+}
+
+// suspendReportedUser suspends a user as the outcome of a report. Banned
+// users stay banned and shadow-banned users stay shadow-banned while
+// suspended; a suspension never shortens one already in place.
+func suspendReportedUser(ctx context.Context, q *database.Queries, moderatorID, userID uuid.UUID, until time.Time, reason string) error {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.AccountState == accountBanned {
+		return nil
+	}
+	if user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(until) {
+		return nil
+	}
+	state := accountSuspended
+	if user.AccountState == accountShadowBanned {
+		state = accountShadowBanned
+	}
+	_, err = setAccountState(ctx, q, user, moderatorID, state, sql.NullTime{Time: until, Valid: true}, reason)
+	return err
+}
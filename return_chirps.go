@@ -2,6 +2,7 @@ package main
 
 import (
 	// "encoding/json"
+	"context"
 	"net/http"
 	"time"
 	"github.com/google/uuid"
@@ -10,12 +11,164 @@ import (
 	"sort"
 )
 
+const (
+	chirpStatusPublished = "published"
+	// chirpStatusHeld chirps are waiting for a moderator and only visible to
+	// their author.
+	chirpStatusHeld = "held"
+	// chirpStatusHidden chirps were taken down by a moderator. Their author
+	// can still see them, with a notice explaining why.
+	chirpStatusHidden = "hidden"
+	// chirpStatusScheduled chirps are waiting for their publish_at time.
+	chirpStatusScheduled = "scheduled"
+	// chirpStatusDraft chirps haven't been posted and are only visible to
+	// their author.
+	chirpStatusDraft = "draft"
+)
+
+var chirpNotices = map[string]string{
+	chirpStatusHeld:      "This chirp is waiting for moderator review and is only visible to you.",
+	chirpStatusHidden:    "This chirp was hidden by a moderator and is only visible to you.",
+	chirpStatusScheduled: "This chirp is scheduled and only visible to you until it is published.",
+	chirpStatusDraft:     "This chirp is a draft and only visible to you.",
+}
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"` 
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Status    string     `json:"status"`
+	Notice    string     `json:"notice,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	RechirpOf *uuid.UUID `json:"rechirp_of,omitempty"`
+	QuoteOf   *uuid.UUID `json:"quote_of,omitempty"`
+	// ReferencedChirp is the rechirped or quoted chirp, expanded one level.
+	ReferencedChirp *Chirp `json:"referenced_chirp,omitempty"`
+	// ReferencedChirpDeleted is set on quote-chirps whose original has since
+	// been deleted. Rechirps are deleted along with their original.
+	ReferencedChirpDeleted bool          `json:"referenced_chirp_deleted,omitempty"`
+	Entities               ChirpEntities `json:"entities"`
+	Attachments            []Attachment  `json:"attachments"`
+	// LinkPreviews are filled in shortly after the chirp is posted.
+	LinkPreviews           []LinkPreview `json:"link_previews"`
+}
+
+func chirpFromDB(dbChirp database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:        dbChirp.ID,
+		CreatedAt: dbChirp.CreatedAt,
+		UpdatedAt: dbChirp.UpdatedAt,
+		UserID:    dbChirp.UserID,
+		Body:      dbChirp.Body,
+		Status:    dbChirp.Status,
+		Notice:    chirpNotices[dbChirp.Status],
+		Entities:  emptyEntities(),
+		Attachments: []Attachment{},
+		LinkPreviews: []LinkPreview{},
+	}
+	if dbChirp.Status == chirpStatusScheduled && dbChirp.PublishAt.Valid {
+		chirp.PublishAt = &dbChirp.PublishAt.Time
+	}
+	if dbChirp.RechirpOf.Valid {
+		chirp.RechirpOf = &dbChirp.RechirpOf.UUID
+	}
+	if dbChirp.QuoteOf.Valid {
+		chirp.QuoteOf = &dbChirp.QuoteOf.UUID
+	}
+	if dbChirp.IsQuote && !dbChirp.QuoteOf.Valid {
+		chirp.ReferencedChirpDeleted = true
+	}
+	return chirp
+}
+
+func referencedChirpID(dbChirp database.Chirp) (uuid.UUID, bool) {
+	if dbChirp.RechirpOf.Valid {
+		return dbChirp.RechirpOf.UUID, true
+	}
+	if dbChirp.QuoteOf.Valid {
+		return dbChirp.QuoteOf.UUID, true
+	}
+	return uuid.UUID{}, false
+}
+
+// expandChirps converts database chirps to their JSON form, fetching every
+// referenced chirp, all entities, attachments and link previews in batched
+// queries. Chirps viewerID isn't allowed to see are dropped, and references
+// to them are shown as deleted.
+func (cfg *apiConfig) expandChirps(ctx context.Context, viewerID uuid.UUID, dbChirps []database.Chirp) ([]Chirp, error) {
+	dbChirps, err := cfg.hideRestrictedChirps(ctx, viewerID, dbChirps)
+	if err != nil {
+		return nil, err
+	}
+
+	refIDs := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		if id, ok := referencedChirpID(dbChirp); ok {
+			refIDs = append(refIDs, id)
+		}
+	}
+
+	referenced := map[uuid.UUID]database.Chirp{}
+	if len(refIDs) > 0 {
+		refs, err := cfg.db.GetChirpsByIDs(ctx, refIDs)
+		if err != nil {
+			return nil, err
+		}
+		refs, err = cfg.hideRestrictedChirps(ctx, viewerID, refs)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			referenced[ref.ID] = ref
+		}
+	}
+
+	chirpIDs := append([]uuid.UUID{}, refIDs...)
+	for _, dbChirp := range dbChirps {
+		chirpIDs = append(chirpIDs, dbChirp.ID)
+	}
+	chirpEntities, err := cfg.loadChirpEntities(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := cfg.loadAttachments(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	previews, err := cfg.loadLinkPreviews(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+	withEntities := func(dbChirp database.Chirp) Chirp {
+		chirp := chirpFromDB(dbChirp)
+		if e, ok := chirpEntities[dbChirp.ID]; ok {
+			chirp.Entities = e
+		}
+		if a, ok := attachments[dbChirp.ID]; ok {
+			chirp.Attachments = a
+		}
+		if p, ok := previews[dbChirp.ID]; ok {
+			chirp.LinkPreviews = p
+		}
+		return chirp
+	}
+
+	chirps := []Chirp{}
+	for _, dbChirp := range dbChirps {
+		chirp := withEntities(dbChirp)
+		if id, ok := referencedChirpID(dbChirp); ok {
+			if ref, found := referenced[id]; found && ref.Status == chirpStatusPublished {
+				refChirp := withEntities(ref)
+				chirp.ReferencedChirp = &refChirp
+			} else {
+				chirp.ReferencedChirpDeleted = true
+			}
+		}
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
 }
 
 func (cfg *apiConfig) handlerChirpsGet(w http.ResponseWriter, r *http.Request) {
@@ -26,19 +179,34 @@ func (cfg *apiConfig) handlerChirpsGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
 	dbChirp, err := cfg.db.GetChirpsByID(r.Context(), chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		UserID:    dbChirp.UserID,
-		Body:      dbChirp.Body,
-	})
+	if dbChirp.Status != chirpStatusPublished && dbChirp.UserID != viewerID {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", nil)
+		return
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), viewerID, []database.Chirp{dbChirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+	if len(chirps) == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't get chirp", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
 }
 
 func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Request) {
@@ -48,22 +216,23 @@ func (cfg *apiConfig) handlerChirpsRetrieve(w http.ResponseWriter, r *http.Reque
 		sortType = "asc"
 	}
 
-	dbChirps, err := cfg.db.GetChirps(r.Context())
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetChirps(r.Context(), viewerID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
 		return
 	}
 
 
-	chirps := []Chirp{}
-	for _, dbChirp := range dbChirps {
-		chirps = append(chirps, Chirp{
-				ID:        dbChirp.ID,
-				CreatedAt: dbChirp.CreatedAt,
-				UpdatedAt: dbChirp.UpdatedAt,
-				UserID:    dbChirp.UserID,
-				Body:      dbChirp.Body,
-		})
+	chirps, err := cfg.expandChirps(r.Context(), viewerID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
 	}
 
 	finalChirps := []Chirp{}
@@ -111,13 +280,12 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
+	if _, err := auth.GetBearerToken(r.Header); err != nil {
 		respondWithError(w, http.StatusUnauthorized, "No token found in header", err)
 		return
 	}
 
-	authUser, err := auth.ValidateJWT(token, cfg.JwtSecret) 
+	authUser, err := cfg.authenticatedUserID(r)
 	if err != nil {
 		respondWithError(w, http.StatusForbidden, "Could not validate token", err)
 		return
@@ -143,6 +311,10 @@ func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusForbidden, "Could not find chirp", err)
 		return
 	}
+	// Drafts, scheduled, held and hidden chirps were never announced.
+	if chirp.Status == chirpStatusPublished {
+		cfg.publishChirpDeleted(r.Context(), chirp)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
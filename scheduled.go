@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/moderation"
+)
+
+const (
+	jobPublishScheduled = "chirps.publish_scheduled"
+	// scheduledChirpsInterval is how often due chirps are published, and so
+	// roughly how late a scheduled chirp can appear.
+	scheduledChirpsInterval = 30 * time.Second
+	maxScheduleAhead        = 365 * 24 * time.Hour
+)
+
+func validatePublishAt(t time.Time) error {
+	now := time.Now()
+	if !t.After(now) {
+		return errors.New("publish_at must be in the future")
+	}
+	if t.After(now.Add(maxScheduleAhead)) {
+		return fmt.Errorf("publish_at can be at most %d days ahead", int(maxScheduleAhead.Hours()/24))
+	}
+	return nil
+}
+
+// jobPublishScheduled publishes every scheduled chirp that is due. Each
+// batch claims its rows with FOR UPDATE SKIP LOCKED and only moves chirps
+// that are still scheduled, so instances running the job at the same time
+// never publish a chirp twice.
+func (cfg *apiConfig) jobPublishScheduled(ctx context.Context, _ json.RawMessage) error {
+	for {
+		chirps, err := cfg.publishDueChirps(ctx)
+		if err != nil {
+			return err
+		}
+		if len(chirps) == 0 {
+			return nil
+		}
+		for _, chirp := range chirps {
+			cfg.publishChirpCreated(ctx, chirp)
+		}
+	}
+}
+
+// publishDueChirps publishes one batch of due chirps. Their entities were
+// saved when they were scheduled, so they are restamped with the publish
+// time for trends to count them. The follow-up jobs are queued in the same
+// transaction, so a crash can't publish a chirp without them.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) ([]database.Chirp, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	chirps, err := q.PublishDueChirps(ctx)
+	if err != nil || len(chirps) == 0 {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	if err := q.RestampChirpHashtags(ctx, ids); err != nil {
+		return nil, err
+	}
+	if err := q.RestampChirpMentions(ctx, ids); err != nil {
+		return nil, err
+	}
+	queue := cfg.jobs.WithTx(tx)
+	for _, chirp := range chirps {
+		if err := enqueueChirpJobs(ctx, queue, chirp); err != nil {
+			return nil, err
+		}
+	}
+	return chirps, tx.Commit()
+}
+
+func (cfg *apiConfig) handlerScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetScheduledChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve scheduled chirps", err)
+		return
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve scheduled chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// handlerUpdateScheduledChirp changes the body or publish time of a chirp
+// that hasn't been published yet. A new body goes through the same checks
+// as a new chirp.
+func (cfg *apiConfig) handlerUpdateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body      *string    `json:"body"`
+		PublishAt *time.Time `json:"publish_at"`
+	}
+
+	user, err := cfg.authenticatedUser(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+	userID := user.ID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	existing, err := cfg.db.GetChirpsByID(r.Context(), chirpID)
+	if err != nil || existing.UserID != userID || existing.Status != chirpStatusScheduled {
+		respondWithError(w, http.StatusNotFound, "Couldn't find scheduled chirp", err)
+		return
+	}
+
+	publishAt := existing.PublishAt
+	if params.PublishAt != nil {
+		if err := validatePublishAt(*params.PublishAt); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		publishAt = sql.NullTime{Time: params.PublishAt.UTC(), Valid: true}
+	}
+
+	body := existing.Body
+	verdict := moderation.Result{Action: moderation.ActionAllow}
+	var spamResult spamCheck
+	if params.Body != nil {
+		limits, err := cfg.userEntitlements(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load account limits", err)
+			return
+		}
+		var ok bool
+		verdict, spamResult, ok = cfg.vetChirp(w, r, user, limits, *params.Body, existing.IsQuote)
+		if !ok {
+			return
+		}
+		body = verdict.Text
+	}
+
+	chirp, err := cfg.updateScheduledChirp(r.Context(), database.UpdateScheduledChirpParams{
+		ID:        chirpID,
+		UserID:    userID,
+		Body:      body,
+		PublishAt: publishAt,
+	}, params.Body != nil, verdict)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Chirp has already been published", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+	if params.Body != nil {
+		cfg.recordSpamCheck(r.Context(), userID, uuid.NullUUID{UUID: chirp.ID, Valid: true}, spamResult)
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), userID, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps[0])
+}
+
+// updateScheduledChirp saves an edit in one transaction, re-extracting
+// entities when the body changed. It returns sql.ErrNoRows if the chirp was
+// published in the meantime.
+func (cfg *apiConfig) updateScheduledChirp(ctx context.Context, params database.UpdateScheduledChirpParams, bodyChanged bool, verdict moderation.Result) (database.Chirp, error) {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	chirp, err := q.UpdateScheduledChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if bodyChanged {
+		if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+			return database.Chirp{}, err
+		}
+		if err := q.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+			return database.Chirp{}, err
+		}
+		if err := saveChirpEntities(ctx, q, chirp); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	if verdict.Action == moderation.ActionHold {
+		if err := q.HoldScheduledChirp(ctx, chirp.ID); err != nil {
+			return database.Chirp{}, err
+		}
+		err := q.HoldChirp(ctx, database.HoldChirpParams{ChirpID: chirp.ID, Reasons: verdict.Reasons()})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		chirp.Status = chirpStatusHeld
+	}
+
+	return chirp, tx.Commit()
+}
+
+func (cfg *apiConfig) handlerCancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	deleted, err := cfg.db.CancelScheduledChirp(r.Context(), database.CancelScheduledChirpParams{
+		ID:     chirpID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't cancel chirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Couldn't find scheduled chirp", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
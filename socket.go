@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/live"
+	"workspace/github.com/Benjysparks/chirpy/internal/stream"
+	"workspace/github.com/Benjysparks/chirpy/internal/websocket"
+)
+
+const (
+	// eventNotification carries a new or updated notification to its
+	// recipient's sockets. It never appears on the SSE stream.
+	eventNotification = "notification"
+
+	channelFeed          = "feed"
+	channelTimeline      = "timeline"
+	channelNotifications = "notifications"
+	// channelThreadPrefix is followed by a chirp ID. The thread is the
+	// chirp and the chirps quoting or rechirping it.
+	channelThreadPrefix = "thread:"
+
+	socketReadLimit    = 4 << 10
+	socketWriteTimeout = 10 * time.Second
+)
+
+// handlerSocket upgrades to a WebSocket and runs a live session on it. The
+// client can authenticate with the usual bearer token on the upgrade
+// request, or with an auth message once connected.
+func (cfg *apiConfig) handlerSocket(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		// Upgrade has already responded.
+		return
+	}
+	conn.SetReadLimit(socketReadLimit)
+	cfg.sockets.Serve(context.Background(), socketConn{conn}, userID)
+}
+
+// socketConn adapts a WebSocket to live.Conn, bounding how long a write can
+// wait on a client that has stopped reading.
+type socketConn struct {
+	conn *websocket.Conn
+}
+
+func (c socketConn) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	return data, err
+}
+
+func (c socketConn) WriteMessage(data []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	return c.conn.WriteMessage(websocket.OpText, data)
+}
+
+func (c socketConn) Ping() error {
+	c.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+	return c.conn.Ping()
+}
+
+func (c socketConn) Close(code int, reason string) error {
+	return c.conn.Close(code, reason)
+}
+
+func (cfg *apiConfig) socketUser(ctx context.Context, token string) (uuid.UUID, error) {
+	user, err := cfg.userFromToken(ctx, token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return user.ID, nil
+}
+
+// socketChannel decides what a session receives on a channel. Who the user
+// follows, blocks and mutes is read when they join, so a client picks up
+// changes by joining again.
+func (cfg *apiConfig) socketChannel(ctx context.Context, userID uuid.UUID, name string) (stream.Filter, error) {
+	switch {
+	case name == channelFeed:
+		audience, err := cfg.loadStreamAudience(ctx, userID, false)
+		if err != nil {
+			return nil, err
+		}
+		return func(e stream.Event) bool {
+			return isChirpEvent(e) && !audience.hidden[e.Author]
+		}, nil
+
+	case name == channelTimeline:
+		if userID == uuid.Nil {
+			return nil, live.ErrUnauthorized
+		}
+		audience, err := cfg.loadStreamAudience(ctx, userID, true)
+		if err != nil {
+			return nil, err
+		}
+		return func(e stream.Event) bool {
+			return isChirpEvent(e) && !audience.hidden[e.Author] &&
+				(audience.following[e.Author] || e.Author == userID)
+		}, nil
+
+	case name == channelNotifications:
+		if userID == uuid.Nil {
+			return nil, live.ErrUnauthorized
+		}
+		return func(e stream.Event) bool {
+			return e.Type == eventNotification && e.Recipient == userID
+		}, nil
+
+	case strings.HasPrefix(name, channelThreadPrefix):
+		chirpID, err := uuid.Parse(strings.TrimPrefix(name, channelThreadPrefix))
+		if err != nil {
+			return nil, live.ErrUnknownChannel
+		}
+		chirp, err := cfg.db.GetChirpsByID(ctx, chirpID)
+		if err != nil || chirp.Status != chirpStatusPublished {
+			return nil, live.ErrUnknownChannel
+		}
+		visible, err := cfg.expandChirps(ctx, userID, []database.Chirp{chirp})
+		if err != nil {
+			return nil, err
+		}
+		if len(visible) == 0 {
+			return nil, live.ErrUnknownChannel
+		}
+		audience, err := cfg.loadStreamAudience(ctx, userID, false)
+		if err != nil {
+			return nil, err
+		}
+		return func(e stream.Event) bool {
+			if !isChirpEvent(e) || audience.hidden[e.Author] {
+				return false
+			}
+			for _, id := range e.Chirps {
+				if id == chirpID {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, live.ErrUnknownChannel
+}
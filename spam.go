@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/moderation"
+	"workspace/github.com/Benjysparks/chirpy/internal/spam"
+)
+
+const (
+	spamVelocityWindow  = 10 * time.Minute
+	spamDuplicateWindow = 24 * time.Hour
+	// spamFingerprintSample caps how many recent fingerprints each new
+	// chirp is compared against.
+	spamFingerprintSample = 2000
+	spamThrottleFor       = 30 * time.Minute
+)
+
+type spamCheck struct {
+	result      spam.Result
+	signals     spam.Signals
+	fingerprint sql.NullInt64
+}
+
+// checkSpam gathers the spam signals for a chirp author is about to post
+// and scores them.
+func (cfg *apiConfig) checkSpam(ctx context.Context, author database.User, body string) (spamCheck, error) {
+	now := time.Now().UTC()
+	check := spamCheck{}
+	check.signals = spam.Signals{
+		Links:      len(moderation.Links(body)),
+		Words:      len(spam.Words(body)),
+		AccountAge: now.Sub(author.CreatedAt),
+	}
+
+	recent, err := cfg.db.CountChirpsSince(ctx, database.CountChirpsSinceParams{
+		UserID:    author.ID,
+		CreatedAt: now.Add(-spamVelocityWindow),
+	})
+	if err != nil {
+		return spamCheck{}, err
+	}
+	check.signals.RecentChirps = int(recent)
+
+	if fp, ok := spam.Fingerprint(body); ok {
+		check.fingerprint = sql.NullInt64{Int64: int64(fp), Valid: true}
+
+		rows, err := cfg.db.GetRecentFingerprints(ctx, database.GetRecentFingerprintsParams{
+			CreatedAt: now.Add(-spamDuplicateWindow),
+			Limit:     spamFingerprintSample,
+		})
+		if err != nil {
+			return spamCheck{}, err
+		}
+		for _, row := range rows {
+			if spam.Distance(fp, uint64(row.Simhash.Int64)) > spam.NearDuplicateDistance {
+				continue
+			}
+			if row.UserID == author.ID {
+				check.signals.DuplicatesSameAuthor++
+			} else {
+				check.signals.DuplicatesOtherAuthors++
+			}
+		}
+	}
+
+	check.result = spam.Score(check.signals, cfg.spamThresholds)
+	return check, nil
+}
+
+// recordSpamCheck stores a check so thresholds can be tuned against real
+// traffic. Every check is kept, including ones that let the chirp through,
+// because their fingerprints feed duplicate detection.
+func (cfg *apiConfig) recordSpamCheck(ctx context.Context, authorID uuid.UUID, chirpID uuid.NullUUID, check spamCheck) {
+	signals, err := json.Marshal(check.signals)
+	if err == nil {
+		err = cfg.db.RecordSpamCheck(ctx, database.RecordSpamCheckParams{
+			UserID:  authorID,
+			ChirpID: chirpID,
+			Simhash: check.fingerprint,
+			Score:   check.result.Score,
+			Action:  string(check.result.Action),
+			Reasons: strings.Join(check.result.Reasons, "; "),
+			Signals: signals,
+		})
+	}
+	if err != nil {
+		log.Printf("Couldn't record spam check: %v", err)
+	}
+}
+
+// throttlePosting stops a user posting for spamThrottleFor.
+func (cfg *apiConfig) throttlePosting(ctx context.Context, userID uuid.UUID) time.Time {
+	until := time.Now().UTC().Add(spamThrottleFor)
+	err := cfg.db.SetPostingThrottle(ctx, database.SetPostingThrottleParams{
+		ID:                    userID,
+		PostingThrottledUntil: sql.NullTime{Time: until, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Couldn't throttle %s: %v", userID, err)
+	}
+	return until
+}
+
+// holdForSpam adds the spam check to a moderation verdict so the chirp goes
+// through the same review queue as chirps held by filters.
+func holdForSpam(verdict moderation.Result, check spamCheck) moderation.Result {
+	verdict.Matches = append(verdict.Matches, moderation.Match{
+		FilterID: "spam",
+		Filter:   "spam (" + strings.Join(check.result.Reasons, "; ") + ")",
+		Action:   moderation.ActionHold,
+	})
+	verdict.Action = moderation.ActionHold
+	return verdict
+}
+
+func respondThrottled(w http.ResponseWriter, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(until).Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, "You're posting too fast, try again later", nil)
+}
+
+type SpamCheck struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	ChirpID   *uuid.UUID      `json:"chirp_id,omitempty"`
+	Score     float64         `json:"score"`
+	Action    string          `json:"action"`
+	Reasons   string          `json:"reasons"`
+	Signals   json.RawMessage `json:"signals"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerSpamChecks(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetSpamChecks(r.Context(), database.GetSpamChecksParams{
+		Action:     r.URL.Query().Get("action"),
+		PageSize:   limit,
+		PageOffset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve spam checks", err)
+		return
+	}
+
+	checks := []SpamCheck{}
+	for _, row := range rows {
+		check := SpamCheck{
+			ID:        row.ID,
+			UserID:    row.UserID,
+			Score:     row.Score,
+			Action:    row.Action,
+			Reasons:   row.Reasons,
+			Signals:   row.Signals,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.ChirpID.Valid {
+			check.ChirpID = &row.ChirpID.UUID
+		}
+		checks = append(checks, check)
+	}
+	respondWithJSON(w, http.StatusOK, checks)
+}
@@ -0,0 +1,42 @@
+-- name: SetAccountState :one
+UPDATE users
+SET account_state = $2,
+    suspended_until = $3,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RecordAccountStateChange :exec
+INSERT INTO account_state_changes (id, user_id, changed_by, from_state, to_state, suspended_until, reason, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW()
+);
+
+-- name: GetAccountStateChanges :many
+SELECT * FROM account_state_changes
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(),
+    updated_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetHiddenAuthors :many
+SELECT id FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+AND (
+    (account_state IN ('shadow_banned', 'banned') AND id <> sqlc.arg(viewer_id)::uuid)
+    OR EXISTS (SELECT 1 FROM blocks WHERE blocker_id = sqlc.arg(viewer_id)::uuid AND blocked_id = users.id)
+    OR EXISTS (SELECT 1 FROM blocks WHERE blocker_id = users.id AND blocked_id = sqlc.arg(viewer_id)::uuid)
+    OR EXISTS (SELECT 1 FROM mutes WHERE muter_id = sqlc.arg(viewer_id)::uuid AND muted_id = users.id)
+);
@@ -0,0 +1,46 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, user_id, content_type, size_bytes, width, height, blob_key, thumbnail_key, thumbnail_content_type, created_at, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments
+WHERE id = $1;
+
+-- name: AttachToChirp :execrows
+UPDATE attachments
+SET chirp_id = sqlc.arg(chirp_id),
+    position = array_position(sqlc.arg(ids)::uuid[], id),
+    updated_at = NOW()
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+AND user_id = sqlc.arg(user_id)
+AND chirp_id IS NULL;
+
+-- name: GetChirpAttachments :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: GetUnattachedAttachments :many
+SELECT * FROM attachments
+WHERE chirp_id IS NULL
+AND updated_at < $1
+ORDER BY updated_at
+LIMIT $2;
+
+-- name: DeleteUnattachedAttachment :execrows
+DELETE FROM attachments
+WHERE id = $1
+AND chirp_id IS NULL;
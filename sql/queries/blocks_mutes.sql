@@ -0,0 +1,59 @@
+-- name: BlockUser :execrows
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1
+AND blocked_id = $2;
+
+-- name: MuteUser :execrows
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM mutes
+WHERE muter_id = $1
+AND muted_id = $2;
+
+-- name: GetBlockedUsers :many
+SELECT users.id, users.username, blocks.created_at FROM blocks
+INNER JOIN users
+ON users.id = blocks.blocked_id
+WHERE blocks.blocker_id = $1
+ORDER BY blocks.created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: GetMutedUsers :many
+SELECT users.id, users.username, mutes.created_at FROM mutes
+INNER JOIN users
+ON users.id = mutes.muted_id
+WHERE mutes.muter_id = $1
+ORDER BY mutes.created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_a)::uuid AND blocked_id = sqlc.arg(user_b)::uuid)
+    OR (blocker_id = sqlc.arg(user_b)::uuid AND blocked_id = sqlc.arg(user_a)::uuid)
+);
+
+-- name: GetBlockersByUsernames :many
+SELECT users.username FROM blocks
+INNER JOIN users
+ON users.id = blocks.blocker_id
+WHERE blocks.blocked_id = sqlc.arg(blocked_id)::uuid
+AND lower(users.username) = ANY(sqlc.arg(usernames)::text[]);
@@ -0,0 +1,23 @@
+-- name: BookmarkChirp :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1
+AND chirp_id = $2;
+
+-- name: GetBookmarkedChirps :many
+SELECT chirps.* FROM bookmarks
+INNER JOIN chirps
+ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = $1
+AND chirps.status = 'published'
+ORDER BY bookmarks.created_at DESC, chirps.id DESC
+LIMIT $2
+OFFSET $3;
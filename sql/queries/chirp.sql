@@ -1,10 +1,21 @@
 -- name: NewChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, is_quote, status, publish_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: CountPostedChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+AND created_at >= $2
+AND status <> 'draft'
+AND rechirp_of IS NULL;
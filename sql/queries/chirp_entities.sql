@@ -0,0 +1,64 @@
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, start_index, end_index, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT DO NOTHING;
+
+-- name: AddChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_index, end_index, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT DO NOTHING;
+
+-- name: GetUsersByUsernames :many
+SELECT id, username FROM users
+WHERE lower(username) = ANY(sqlc.arg(usernames)::text[]);
+
+-- name: GetHashtagsForChirps :many
+SELECT * FROM chirp_hashtags
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY start_index;
+
+-- name: GetMentionsForChirps :many
+SELECT chirp_mentions.chirp_id, chirp_mentions.user_id, chirp_mentions.start_index, chirp_mentions.end_index, users.username FROM chirp_mentions
+INNER JOIN users ON users.id = chirp_mentions.user_id
+WHERE chirp_mentions.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_mentions.start_index;
+
+-- name: GetChirpsByHashtag :many
+SELECT * FROM chirps
+WHERE id IN (
+    SELECT chirp_id FROM chirp_hashtags WHERE tag = sqlc.arg(tag)
+)
+AND status = 'published'
+AND created_at < sqlc.arg(before)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetChirpsMentioningUser :many
+SELECT * FROM chirps
+WHERE id IN (
+    SELECT chirp_id FROM chirp_mentions WHERE chirp_mentions.user_id = sqlc.arg(mentioned_user_id)
+)
+AND status = 'published'
+AND created_at < sqlc.arg(before)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
@@ -0,0 +1,137 @@
+-- name: GetDirectConversation :one
+SELECT * FROM conversations
+WHERE direct_key = $1;
+
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_by, direct_key, created_at, last_message_at)
+VALUES (gen_random_uuid(), $1, $2, NOW(), NOW())
+ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: GetConversationForMember :one
+SELECT conversations.* FROM conversations
+INNER JOIN conversation_members
+ON conversation_members.conversation_id = conversations.id
+WHERE conversations.id = $1
+AND conversation_members.user_id = $2;
+
+-- name: GetConversationsForUser :many
+SELECT conversations.id, conversations.created_at, conversations.last_message_at, (
+    SELECT COUNT(*) FROM messages
+    WHERE messages.conversation_id = conversations.id
+    AND messages.sender_id <> conversation_members.user_id
+    AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+) AS unread_count
+FROM conversations
+INNER JOIN conversation_members
+ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.last_message_at DESC, conversations.id DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: CountUnreadConversations :one
+SELECT COUNT(*) FROM conversation_members
+WHERE conversation_members.user_id = $1
+AND EXISTS (
+    SELECT 1 FROM messages
+    WHERE messages.conversation_id = conversation_members.conversation_id
+    AND messages.sender_id <> conversation_members.user_id
+    AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+);
+
+-- name: GetConversationMembers :many
+SELECT conversation_members.conversation_id, users.id, users.username, conversation_members.last_read_at FROM conversation_members
+INNER JOIN users ON users.id = conversation_members.user_id
+WHERE conversation_members.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_members.joined_at, users.username;
+
+-- name: GetLatestMessages :many
+SELECT DISTINCT ON (conversation_id) * FROM messages
+WHERE conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_id, created_at DESC, id DESC;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, conversation_id, sender_id, body, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW())
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET last_message_at = $2
+WHERE id = $1;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = $1
+AND created_at < $2
+ORDER BY created_at DESC, id DESC
+LIMIT $3;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = sqlc.arg(read_at)::timestamp
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND user_id = sqlc.arg(user_id)
+AND (last_read_at IS NULL OR last_read_at < sqlc.arg(read_at)::timestamp);
+
+-- name: GetMessageSetting :one
+SELECT COALESCE((
+    SELECT allow_from FROM message_settings
+    WHERE user_id = $1
+), 'everyone')::text AS allow_from;
+
+-- name: SetMessageSetting :exec
+INSERT INTO message_settings (user_id, allow_from)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET allow_from = EXCLUDED.allow_from;
+
+-- name: CountUsersByIDs :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetMessageRefusals :many
+SELECT users.id FROM users
+LEFT JOIN message_settings ON message_settings.user_id = users.id
+WHERE users.id = ANY(sqlc.arg(recipient_ids)::uuid[])
+AND (
+    users.account_state = 'banned'
+    OR EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocks.blocker_id = users.id AND blocks.blocked_id = sqlc.arg(sender_id)::uuid)
+        OR (blocks.blocker_id = sqlc.arg(sender_id)::uuid AND blocks.blocked_id = users.id)
+    )
+    OR (
+        message_settings.allow_from = 'following'
+        AND NOT EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = users.id
+            AND follows.followee_id = sqlc.arg(sender_id)::uuid
+        )
+    )
+);
+
+-- name: HasBlockedConversationMember :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members
+    INNER JOIN blocks
+    ON (blocks.blocker_id = conversation_members.user_id AND blocks.blocked_id = sqlc.arg(sender_id)::uuid)
+    OR (blocks.blocker_id = sqlc.arg(sender_id)::uuid AND blocks.blocked_id = conversation_members.user_id)
+    WHERE conversation_members.conversation_id = sqlc.arg(conversation_id)
+    AND conversation_members.user_id <> sqlc.arg(sender_id)::uuid
+);
+
+-- name: CountUnreadMessages :one
+SELECT COUNT(*) FROM messages
+INNER JOIN conversation_members
+ON conversation_members.conversation_id = messages.conversation_id
+WHERE messages.conversation_id = $1
+AND conversation_members.user_id = $2
+AND messages.sender_id <> conversation_members.user_id
+AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at);
@@ -0,0 +1,51 @@
+-- name: CreateDraft :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quote_of, is_quote, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    'draft'
+)
+RETURNING *;
+
+-- name: GetDrafts :many
+SELECT * FROM chirps
+WHERE user_id = $1
+AND status = 'draft'
+ORDER BY updated_at DESC;
+
+-- name: GetDraft :one
+SELECT * FROM chirps
+WHERE id = $1
+AND user_id = $2
+AND status = 'draft';
+
+-- name: UpdateDraft :one
+UPDATE chirps
+SET body = $3,
+    updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND status = 'draft'
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM chirps
+WHERE id = $1
+AND user_id = $2
+AND status = 'draft';
+
+-- name: PublishDraft :one
+UPDATE chirps
+SET body = $2,
+    status = $3,
+    publish_at = $4,
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND status = 'draft'
+RETURNING *;
@@ -0,0 +1,39 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+AND followee_id = $2;
+
+-- name: GetFollowers :many
+SELECT users.id, users.username FROM follows
+INNER JOIN users
+ON users.id = follows.follower_id
+WHERE follows.followee_id = $1
+ORDER BY follows.created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: GetFollowing :many
+SELECT users.id, users.username FROM follows
+INNER JOIN users
+ON users.id = follows.followee_id
+WHERE follows.follower_id = $1
+ORDER BY follows.created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: CountFollowers :one
+SELECT COUNT(*) FROM follows
+WHERE followee_id = $1;
+
+-- name: CountFollowing :one
+SELECT COUNT(*) FROM follows
+WHERE follower_id = $1;
@@ -1,3 +1,5 @@
 -- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at;
\ No newline at end of file
+SELECT * FROM chirps
+WHERE status = 'published'
+OR (status = 'hidden' AND user_id = sqlc.arg(viewer_id))
+ORDER BY created_at;
@@ -0,0 +1,57 @@
+-- name: EnqueueJob :exec
+INSERT INTO jobs (id, kind, payload, status, attempts, max_attempts, dedupe_key, run_at, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    'pending',
+    0,
+    $3,
+    $4,
+    $5,
+    NOW(),
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: ClaimJob :one
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_at = NOW(),
+    updated_at = NOW()
+WHERE id = (
+    SELECT id FROM jobs
+    WHERE (status = 'pending' AND run_at <= NOW())
+    OR (status = 'running' AND locked_at < sqlc.arg(stale_before)::timestamp)
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+DELETE FROM jobs
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending',
+    run_at = $2,
+    last_error = $3,
+    locked_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailJob :exec
+UPDATE jobs
+SET status = 'failed',
+    last_error = $2,
+    locked_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: PruneFailedJobs :execrows
+DELETE FROM jobs
+WHERE status = 'failed'
+AND updated_at < $1;
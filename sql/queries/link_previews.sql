@@ -0,0 +1,27 @@
+-- name: GetLinkPreview :one
+SELECT * FROM link_previews
+WHERE url = $1;
+
+-- name: SaveLinkPreview :exec
+INSERT INTO link_previews (url, status, title, description, image_url, site_name, fetched_at)
+VALUES ($1, $2, $3, $4, $5, $6, NOW())
+ON CONFLICT (url) DO UPDATE
+SET status = EXCLUDED.status,
+    title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    image_url = EXCLUDED.image_url,
+    site_name = EXCLUDED.site_name,
+    fetched_at = EXCLUDED.fetched_at;
+
+-- name: AddChirpLink :exec
+INSERT INTO chirp_links (chirp_id, url, position)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: GetLinkPreviewsForChirps :many
+SELECT chirp_links.chirp_id, link_previews.url, link_previews.title, link_previews.description, link_previews.image_url, link_previews.site_name
+FROM chirp_links
+INNER JOIN link_previews ON link_previews.url = chirp_links.url
+WHERE chirp_links.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+AND link_previews.status = 'ok'
+ORDER BY chirp_links.chirp_id, chirp_links.position;
@@ -0,0 +1,71 @@
+-- name: CreateList :one
+INSERT INTO lists (id, owner_id, name, description, is_private, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: GetList :one
+SELECT lists.*, (
+    SELECT COUNT(*) FROM list_members WHERE list_members.list_id = lists.id
+) AS member_count
+FROM lists
+WHERE id = $1;
+
+-- name: GetListsByOwner :many
+SELECT lists.*, (
+    SELECT COUNT(*) FROM list_members WHERE list_members.list_id = lists.id
+) AS member_count
+FROM lists
+WHERE owner_id = sqlc.arg(owner_id)
+AND (NOT is_private OR sqlc.arg(include_private)::boolean)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size)
+OFFSET sqlc.arg(page_offset);
+
+-- name: CountListsByOwner :one
+SELECT COUNT(*) FROM lists
+WHERE owner_id = $1;
+
+-- name: UpdateList :exec
+UPDATE lists
+SET name = $2,
+    description = $3,
+    is_private = $4,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteList :exec
+DELETE FROM lists
+WHERE id = $1;
+
+-- name: AddListMember :execrows
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1
+AND user_id = $2;
+
+-- name: GetListMembers :many
+SELECT users.id, users.username FROM list_members
+INNER JOIN users
+ON users.id = list_members.user_id
+WHERE list_members.list_id = $1
+ORDER BY list_members.created_at DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: GetListTimeline :many
+SELECT * FROM chirps
+WHERE user_id IN (
+    SELECT user_id FROM list_members WHERE list_id = sqlc.arg(list_id)
+)
+AND status = 'published'
+AND created_at < sqlc.arg(before)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,90 @@
+-- name: GetModerationFilters :many
+SELECT * FROM moderation_filters
+ORDER BY position, created_at;
+
+-- name: GetModerationFilter :one
+SELECT * FROM moderation_filters
+WHERE id = $1;
+
+-- name: GetModerationTerms :many
+SELECT * FROM moderation_terms
+ORDER BY filter_id, term;
+
+-- name: GetModerationFilterTerms :many
+SELECT term FROM moderation_terms
+WHERE filter_id = $1
+ORDER BY term;
+
+-- name: CreateModerationFilter :one
+INSERT INTO moderation_filters (id, kind, name, action, pattern, position, enabled, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: UpdateModerationFilter :one
+UPDATE moderation_filters
+SET name = $2,
+    action = $3,
+    pattern = $4,
+    position = $5,
+    enabled = $6,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteModerationFilter :execrows
+DELETE FROM moderation_filters
+WHERE id = $1;
+
+-- name: AddModerationTerm :exec
+INSERT INTO moderation_terms (filter_id, term, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteModerationTerm :execrows
+DELETE FROM moderation_terms
+WHERE filter_id = $1
+AND term = $2;
+
+-- name: HoldChirp :exec
+INSERT INTO held_chirps (chirp_id, reasons, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: GetHeldChirps :many
+SELECT chirps.*, held_chirps.reasons FROM held_chirps
+INNER JOIN chirps ON chirps.id = held_chirps.chirp_id
+ORDER BY held_chirps.created_at;
+
+-- name: ReleaseHeldChirp :one
+UPDATE chirps
+SET status = CASE WHEN publish_at > NOW() THEN 'scheduled' ELSE 'published' END,
+    updated_at = NOW()
+WHERE id = $1
+AND status = 'held'
+RETURNING *;
+
+-- name: DeleteHeldChirp :execrows
+DELETE FROM chirps
+WHERE id = $1
+AND status = 'held';
+
+-- name: ClearChirpHold :exec
+DELETE FROM held_chirps
+WHERE chirp_id = $1;
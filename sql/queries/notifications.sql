@@ -0,0 +1,60 @@
+-- name: UpsertNotification :one
+INSERT INTO notifications (id, user_id, type, chirp_id, group_key, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+ON CONFLICT (user_id, group_key) WHERE read_at IS NULL
+DO UPDATE SET updated_at = NOW()
+RETURNING *;
+
+-- name: AddNotificationActor :exec
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY updated_at DESC, id DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: GetNotificationActors :many
+SELECT notification_actors.notification_id, notification_actors.actor_id, users.username FROM notification_actors
+INNER JOIN users ON users.id = notification_actors.actor_id
+WHERE notification_actors.notification_id = ANY(sqlc.arg(notification_ids)::uuid[])
+ORDER BY notification_actors.created_at DESC;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :many
+SELECT type, enabled FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled;
+
+-- name: NotificationEnabled :one
+SELECT NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE user_id = $1
+    AND type = $2
+    AND NOT enabled
+);
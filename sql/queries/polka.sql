@@ -0,0 +1,14 @@
+-- name: ClaimPolkaEvent :execrows
+INSERT INTO polka_events (event_id, event, processed_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (event_id) DO NOTHING;
+
+-- name: LogPolkaWebhook :exec
+INSERT INTO polka_webhook_log (id, event_id, event, user_id, body, status, error, received_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, NOW());
+
+-- name: GetPolkaWebhookLog :many
+SELECT * FROM polka_webhook_log
+ORDER BY received_at DESC, id DESC
+LIMIT $1
+OFFSET $2;
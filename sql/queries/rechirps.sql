@@ -0,0 +1,21 @@
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, rechirp_of)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: DeleteRechirp :many
+DELETE FROM chirps
+WHERE user_id = $1
+AND rechirp_of = $2
+RETURNING id;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
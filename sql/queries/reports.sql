@@ -0,0 +1,70 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, reporter_id, reported_user_id, chirp_id, reason, details, status, created_at, updated_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    'open',
+    NOW(),
+    NOW()
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: GetReportsByStatus :many
+SELECT * FROM reports
+WHERE status = $1
+ORDER BY created_at
+LIMIT $2
+OFFSET $3;
+
+-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed',
+    claimed_by = $2,
+    claimed_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND (status = 'open' OR (status = 'claimed' AND claimed_by = $2))
+RETURNING *;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = 'resolved',
+    resolved_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND status = 'claimed'
+AND claimed_by = $2
+RETURNING *;
+
+-- name: RecordModerationDecision :one
+INSERT INTO moderation_decisions (id, report_id, moderator_id, subject_user_id, chirp_id, action, note, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetModerationDecisionsForReport :many
+SELECT * FROM moderation_decisions
+WHERE report_id = $1
+ORDER BY created_at;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET status = 'hidden',
+    updated_at = NOW()
+WHERE id = $1;
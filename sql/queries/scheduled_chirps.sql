@@ -0,0 +1,57 @@
+-- name: GetScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+AND status = 'scheduled'
+ORDER BY publish_at;
+
+-- name: UpdateScheduledChirp :one
+UPDATE chirps
+SET body = $3,
+    publish_at = $4,
+    updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND status = 'scheduled'
+RETURNING *;
+
+-- name: HoldScheduledChirp :exec
+UPDATE chirps
+SET status = 'held',
+    updated_at = NOW()
+WHERE id = $1
+AND status = 'scheduled';
+
+-- name: CancelScheduledChirp :execrows
+DELETE FROM chirps
+WHERE id = $1
+AND user_id = $2
+AND status = 'scheduled';
+
+-- name: PublishDueChirps :many
+UPDATE chirps
+SET status = 'published',
+    created_at = NOW(),
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE status = 'scheduled'
+    AND publish_at <= NOW()
+    ORDER BY publish_at
+    LIMIT 500
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RestampChirpHashtags :exec
+UPDATE chirp_hashtags
+SET created_at = chirps.created_at
+FROM chirps
+WHERE chirps.id = chirp_hashtags.chirp_id
+AND chirp_hashtags.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: RestampChirpMentions :exec
+UPDATE chirp_mentions
+SET created_at = chirps.created_at
+FROM chirps
+WHERE chirps.id = chirp_mentions.chirp_id
+AND chirp_mentions.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
@@ -0,0 +1,38 @@
+-- name: RecordSpamCheck :exec
+INSERT INTO spam_checks (id, user_id, chirp_id, simhash, score, action, reasons, signals, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    NOW()
+);
+
+-- name: GetRecentFingerprints :many
+SELECT user_id, simhash FROM spam_checks
+WHERE simhash IS NOT NULL
+AND created_at > $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: CountChirpsSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1
+AND created_at > $2;
+
+-- name: SetPostingThrottle :exec
+UPDATE users
+SET posting_throttled_until = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetSpamChecks :many
+SELECT * FROM spam_checks
+WHERE sqlc.arg(action)::text = '' OR action = sqlc.arg(action)::text
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size)
+OFFSET sqlc.arg(page_offset);
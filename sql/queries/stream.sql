@@ -0,0 +1,19 @@
+-- name: NextStreamEventID :one
+SELECT nextval('stream_event_ids')::bigint AS id;
+
+-- name: NotifyStreamEvent :exec
+SELECT pg_notify(sqlc.arg(channel)::text, sqlc.arg(payload)::text);
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id FROM follows
+WHERE follower_id = $1;
+
+-- name: GetBlockedOrMutedIDs :many
+SELECT blocked_id AS user_id FROM blocks
+WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id FROM blocks
+WHERE blocked_id = sqlc.arg(user_id)
+UNION
+SELECT muted_id FROM mutes
+WHERE muter_id = sqlc.arg(user_id);
@@ -0,0 +1,31 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: ActivateSubscription :one
+INSERT INTO subscriptions (user_id, plan, status, current_period_end, created_at, updated_at)
+VALUES ($1, $2, 'active', $3, NOW(), NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions
+SET status = 'past_due', updated_at = NOW()
+WHERE user_id = $1 AND status IN ('active', 'past_due');
+
+-- name: CancelSubscription :execrows
+UPDATE subscriptions
+SET status = 'canceled', canceled_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND status IN ('active', 'past_due');
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE (status IN ('active', 'canceled') AND current_period_end <= sqlc.arg(now)::timestamp)
+   OR (status = 'past_due' AND current_period_end <= sqlc.arg(grace_cutoff)::timestamp)
+RETURNING user_id;
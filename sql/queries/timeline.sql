@@ -0,0 +1,9 @@
+-- name: GetHomeTimeline :many
+SELECT * FROM chirps
+WHERE (user_id = sqlc.arg(viewer_id) OR user_id IN (
+    SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(viewer_id)
+))
+AND status = 'published'
+AND created_at < sqlc.arg(before)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
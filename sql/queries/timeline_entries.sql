@@ -0,0 +1,53 @@
+-- name: AddOwnTimelineEntry :exec
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT chirps.user_id, chirps.id, chirps.user_id, chirps.created_at
+FROM chirps
+WHERE chirps.id = $1
+ON CONFLICT DO NOTHING;
+
+-- name: FanOutChirp :execrows
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT follows.follower_id, chirps.id, chirps.user_id, chirps.created_at
+FROM chirps
+INNER JOIN follows ON follows.followee_id = chirps.user_id
+WHERE chirps.id = $1
+ON CONFLICT DO NOTHING;
+
+-- name: BackfillTimeline :execrows
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT sqlc.arg(follower_id)::uuid, chirps.id, chirps.user_id, chirps.created_at
+FROM chirps
+WHERE chirps.user_id = sqlc.arg(followee_id)
+AND chirps.status = 'published'
+ORDER BY chirps.created_at DESC
+LIMIT sqlc.arg(max_entries)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveTimelineEntriesByAuthor :exec
+DELETE FROM timeline_entries
+WHERE user_id = $1
+AND author_id = $2;
+
+-- name: GetMaterializedTimeline :many
+SELECT chirps.* FROM timeline_entries
+INNER JOIN chirps ON chirps.id = timeline_entries.chirp_id
+WHERE timeline_entries.user_id = sqlc.arg(viewer_id)
+AND chirps.status = 'published'
+AND timeline_entries.created_at < sqlc.arg(before)
+ORDER BY timeline_entries.created_at DESC, timeline_entries.chirp_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetHighFollowerTimeline :many
+SELECT chirps.* FROM chirps
+INNER JOIN follows ON follows.followee_id = chirps.user_id
+INNER JOIN follower_counts ON follower_counts.user_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg(viewer_id)
+AND follower_counts.followers > sqlc.arg(follower_threshold)
+AND chirps.status = 'published'
+AND chirps.created_at < sqlc.arg(before)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetFollowerCount :one
+SELECT followers FROM follower_counts
+WHERE user_id = $1;
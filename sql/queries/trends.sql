@@ -0,0 +1,45 @@
+-- name: GetHashtagCounts :many
+SELECT tag, COUNT(*) FILTER (WHERE created_at >= sqlc.arg(recent_since)) AS recent_count, COUNT(*) AS total_count FROM chirp_hashtags
+WHERE created_at >= sqlc.arg(baseline_since)
+AND tag NOT IN (SELECT tag FROM suppressed_tags)
+AND chirp_id IN (SELECT id FROM chirps WHERE status = 'published')
+GROUP BY tag;
+
+-- name: ClearTrendingTags :exec
+DELETE FROM trending_tags
+WHERE time_window = $1;
+
+-- name: AddTrendingTag :exec
+INSERT INTO trending_tags (time_window, rank, tag, score, recent_count, baseline_rate, computed_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: GetTrendingTags :many
+SELECT * FROM trending_tags
+WHERE tag NOT IN (SELECT tag FROM suppressed_tags)
+ORDER BY time_window, rank;
+
+-- name: SuppressTag :exec
+INSERT INTO suppressed_tags (tag, suppressed_by, reason, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (tag) DO UPDATE SET suppressed_by = EXCLUDED.suppressed_by, reason = EXCLUDED.reason;
+
+-- name: UnsuppressTag :execrows
+DELETE FROM suppressed_tags
+WHERE tag = $1;
+
+-- name: GetSuppressedTags :many
+SELECT * FROM suppressed_tags
+ORDER BY created_at DESC;
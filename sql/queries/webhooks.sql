@@ -0,0 +1,81 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, user_id, url, secret, events, global, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW(), NOW())
+RETURNING *;
+
+-- name: GetWebhooks :many
+SELECT * FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: CountWebhooks :one
+SELECT COUNT(*) FROM webhooks
+WHERE user_id = $1;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1
+AND user_id = $2;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $3, events = $4, active = $5, updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+RETURNING *;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1
+AND user_id = $2;
+
+-- name: GetWebhooksForEvent :many
+SELECT * FROM webhooks
+WHERE active
+AND sqlc.arg(event)::text = ANY(events)
+AND (global OR user_id = ANY(sqlc.arg(user_ids)::uuid[]));
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, payload, status, next_attempt_at, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, 'pending', NOW(), NOW(), NOW())
+ON CONFLICT (webhook_id, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookDeliveryForSend :one
+SELECT webhook_deliveries.id, webhook_deliveries.event, webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts, webhooks.url, webhooks.secret, webhooks.active FROM webhook_deliveries
+INNER JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+WHERE webhook_deliveries.id = $1;
+
+-- name: RecordWebhookAttempt :exec
+INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, status_code, error, response_body, duration_ms)
+VALUES ($1, NOW(), $2, $3, $4, $5);
+
+-- name: UpdateWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = $3, next_attempt_at = $4, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+OFFSET $3;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1
+AND webhook_id = $2;
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempted_at;
+
+-- name: ResetWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1
+AND webhook_id = $2
+AND status IN ('succeeded', 'dead')
+RETURNING *;
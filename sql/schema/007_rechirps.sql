@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE chirps ADD rechirp_of UUID REFERENCES chirps(id) ON DELETE CASCADE;
+ALTER TABLE chirps ADD quote_of UUID REFERENCES chirps(id) ON DELETE SET NULL;
+ALTER TABLE chirps ADD is_quote BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE UNIQUE INDEX chirps_user_rechirp_idx ON chirps (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_user_rechirp_idx;
+ALTER TABLE chirps DROP COLUMN is_quote;
+ALTER TABLE chirps DROP COLUMN quote_of;
+ALTER TABLE chirps DROP COLUMN rechirp_of;
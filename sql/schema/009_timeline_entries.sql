@@ -0,0 +1,68 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    dedupe_key TEXT,
+    run_at TIMESTAMP NOT NULL,
+    locked_at TIMESTAMP,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX jobs_runnable_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX jobs_dedupe_idx ON jobs (dedupe_key) WHERE dedupe_key IS NOT NULL AND status <> 'failed';
+
+CREATE TABLE timeline_entries (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX timeline_entries_user_created_idx ON timeline_entries (user_id, created_at DESC);
+CREATE INDEX timeline_entries_user_author_idx ON timeline_entries (user_id, author_id);
+
+CREATE TABLE follower_counts (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    followers BIGINT NOT NULL
+);
+
+-- +goose StatementBegin
+CREATE FUNCTION update_follower_counts() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO follower_counts (user_id, followers) VALUES (NEW.followee_id, 1)
+        ON CONFLICT (user_id) DO UPDATE SET followers = follower_counts.followers + 1;
+    ELSE
+        UPDATE follower_counts SET followers = followers - 1 WHERE user_id = OLD.followee_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follows_count_trigger
+AFTER INSERT OR DELETE ON follows
+FOR EACH ROW EXECUTE FUNCTION update_follower_counts();
+
+INSERT INTO follower_counts (user_id, followers)
+SELECT followee_id, COUNT(*) FROM follows GROUP BY followee_id;
+
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT user_id, id, user_id, created_at FROM chirps;
+
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT follows.follower_id, chirps.id, chirps.user_id, chirps.created_at
+FROM follows
+INNER JOIN chirps ON chirps.user_id = follows.followee_id;
+
+-- +goose Down
+DROP TRIGGER follows_count_trigger ON follows;
+DROP FUNCTION update_follower_counts;
+DROP TABLE follower_counts;
+DROP TABLE timeline_entries;
+DROP TABLE jobs;
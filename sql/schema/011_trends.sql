@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users ADD role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE trending_tags (
+    time_window TEXT NOT NULL,
+    rank INTEGER NOT NULL,
+    tag TEXT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    recent_count BIGINT NOT NULL,
+    baseline_rate DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (time_window, rank)
+);
+
+CREATE TABLE suppressed_tags (
+    tag TEXT PRIMARY KEY,
+    suppressed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE suppressed_tags;
+DROP TABLE trending_tags;
+ALTER TABLE users DROP COLUMN role;
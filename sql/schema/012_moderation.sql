@@ -0,0 +1,42 @@
+-- +goose Up
+ALTER TABLE chirps ADD status TEXT NOT NULL DEFAULT 'published'
+    CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held'));
+
+CREATE TABLE moderation_filters (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('word_list', 'regex', 'link')),
+    name TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'hold', 'reject')),
+    pattern TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE moderation_terms (
+    filter_id UUID NOT NULL REFERENCES moderation_filters(id) ON DELETE CASCADE,
+    term TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (filter_id, term)
+);
+
+CREATE TABLE held_chirps (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    reasons TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- Seed the word list that used to be hard-coded in handlerChirpsValidate.
+INSERT INTO moderation_filters (id, kind, name, action, pattern, position, enabled, created_at, updated_at)
+VALUES ('6f0c8a52-5a4e-4c43-9f0e-2d1c9b1f7a10', 'word_list', 'profanity', 'mask', '', 1, TRUE, NOW(), NOW());
+
+INSERT INTO moderation_terms (filter_id, term, created_at)
+SELECT '6f0c8a52-5a4e-4c43-9f0e-2d1c9b1f7a10', term, NOW()
+FROM unnest(ARRAY['kerfuffle', 'sharbert', 'fornax']) AS term;
+
+-- +goose Down
+DROP TABLE held_chirps;
+DROP TABLE moderation_terms;
+DROP TABLE moderation_filters;
+ALTER TABLE chirps DROP COLUMN status;
@@ -0,0 +1,57 @@
+-- +goose Up
+ALTER TABLE chirps DROP CONSTRAINT chirps_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden'));
+
+ALTER TABLE users ADD suspended_until TIMESTAMP;
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reported_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'misinformation', 'other')),
+    details TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('open', 'claimed', 'resolved')),
+    claimed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+-- Decisions deliberately have no foreign keys: the record must outlive the
+-- report, the chirp and both accounts involved.
+CREATE TABLE moderation_decisions (
+    id UUID PRIMARY KEY,
+    report_id UUID NOT NULL,
+    moderator_id UUID NOT NULL,
+    subject_user_id UUID NOT NULL,
+    chirp_id UUID,
+    action TEXT NOT NULL CHECK (action IN ('dismiss', 'hide_chirp', 'suspend_user')),
+    note TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX moderation_decisions_report_idx ON moderation_decisions (report_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION forbid_moderation_decision_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'moderation decisions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER moderation_decisions_immutable
+BEFORE UPDATE OR DELETE ON moderation_decisions
+FOR EACH ROW EXECUTE FUNCTION forbid_moderation_decision_changes();
+
+-- +goose Down
+DROP TRIGGER moderation_decisions_immutable ON moderation_decisions;
+DROP FUNCTION forbid_moderation_decision_changes;
+DROP TABLE moderation_decisions;
+DROP TABLE reports;
+ALTER TABLE users DROP COLUMN suspended_until;
+UPDATE chirps SET status = 'published' WHERE status = 'hidden';
+ALTER TABLE chirps DROP CONSTRAINT chirps_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held'));
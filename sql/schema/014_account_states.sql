@@ -0,0 +1,36 @@
+-- +goose Up
+ALTER TABLE users ADD account_state TEXT NOT NULL DEFAULT 'active'
+    CONSTRAINT users_account_state_check CHECK (account_state IN ('active', 'suspended', 'shadow_banned', 'banned'));
+UPDATE users SET account_state = 'suspended' WHERE suspended_until > NOW();
+
+-- Like moderation_decisions, the audit log has no foreign keys so it
+-- survives account deletion.
+CREATE TABLE account_state_changes (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    changed_by UUID NOT NULL,
+    from_state TEXT NOT NULL,
+    to_state TEXT NOT NULL,
+    suspended_until TIMESTAMP,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX account_state_changes_user_idx ON account_state_changes (user_id, created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION forbid_account_state_change_edits() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'account state changes are immutable';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER account_state_changes_immutable
+BEFORE UPDATE OR DELETE ON account_state_changes
+FOR EACH ROW EXECUTE FUNCTION forbid_account_state_change_edits();
+
+-- +goose Down
+DROP TRIGGER account_state_changes_immutable ON account_state_changes;
+DROP FUNCTION forbid_account_state_change_edits;
+DROP TABLE account_state_changes;
+ALTER TABLE users DROP COLUMN account_state;
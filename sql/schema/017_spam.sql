@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE users ADD posting_throttled_until TIMESTAMP;
+
+CREATE TABLE spam_checks (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    simhash BIGINT,
+    score DOUBLE PRECISION NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('allow', 'hold', 'reject', 'throttle')),
+    reasons TEXT NOT NULL,
+    signals JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX spam_checks_created_idx ON spam_checks (created_at);
+
+-- +goose Down
+DROP TABLE spam_checks;
+ALTER TABLE users DROP COLUMN posting_throttled_until;
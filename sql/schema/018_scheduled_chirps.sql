@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE chirps ADD publish_at TIMESTAMP;
+ALTER TABLE chirps DROP CONSTRAINT chirps_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden', 'scheduled'));
+CREATE INDEX chirps_scheduled_idx ON chirps (publish_at) WHERE status = 'scheduled';
+
+-- +goose Down
+DELETE FROM chirps WHERE status = 'scheduled';
+DROP INDEX chirps_scheduled_idx;
+ALTER TABLE chirps DROP CONSTRAINT chirps_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden'));
+ALTER TABLE chirps DROP COLUMN publish_at;
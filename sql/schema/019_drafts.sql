@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE chirps DROP CONSTRAINT chirps_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden', 'scheduled', 'draft'));
+
+-- +goose Down
+DELETE FROM chirps WHERE status = 'draft';
+ALTER TABLE chirps DROP CONSTRAINT chirps_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_status_check CHECK (status IN ('published', 'held', 'hidden', 'scheduled'));
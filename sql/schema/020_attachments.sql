@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE attachments (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- chirp_id is NULL until the upload is attached, and again once its
+    -- chirp is deleted; old unattached rows are garbage-collected.
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    blob_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    thumbnail_content_type TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX attachments_chirp_idx ON attachments (chirp_id, position);
+CREATE INDEX attachments_unattached_idx ON attachments (updated_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE attachments;
@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE link_previews (
+    url TEXT PRIMARY KEY,
+    -- failed previews are cached too, so a broken site isn't fetched again
+    -- for every chirp that links to it.
+    status TEXT NOT NULL CHECK (status IN ('ok', 'failed')),
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    fetched_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE chirp_links (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, url)
+);
+
+-- +goose Down
+DROP TABLE chirp_links;
+DROP TABLE link_previews;
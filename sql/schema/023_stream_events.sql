@@ -0,0 +1,7 @@
+-- +goose Up
+-- Stream event IDs come from here when several processes share events over
+-- LISTEN/NOTIFY, so every process agrees on them.
+CREATE SEQUENCE stream_event_ids;
+
+-- +goose Down
+DROP SEQUENCE stream_event_ids;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- Every request to the Polka webhook, including rejected ones.
+CREATE TABLE polka_webhook_log (
+    id UUID PRIMARY KEY,
+    event_id TEXT,
+    event TEXT NOT NULL,
+    user_id UUID,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('processed', 'ignored', 'duplicate', 'rejected', 'failed')),
+    error TEXT,
+    received_at TIMESTAMP NOT NULL
+);
+CREATE INDEX polka_webhook_log_received_idx ON polka_webhook_log (received_at DESC);
+
+-- One row per event that has been applied, so replays are no-ops.
+CREATE TABLE polka_events (
+    event_id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    processed_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE polka_events;
+DROP TABLE polka_webhook_log;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- One row per user who has ever subscribed. users.is_chirpy_red is kept in
+-- step for API responses, but features go through entitlements instead.
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'canceled', 'expired')),
+    current_period_end TIMESTAMP NOT NULL,
+    canceled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE INDEX subscriptions_period_end_idx ON subscriptions (current_period_end)
+    WHERE status <> 'expired';
+
+-- Existing members get a fresh period; Polka's next renewal takes over.
+INSERT INTO subscriptions (user_id, plan, status, current_period_end, created_at, updated_at)
+SELECT id, 'red', 'active', NOW() + INTERVAL '30 days', NOW(), NOW()
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,43 @@
+-- +goose Up
+-- direct_key is set on one-to-one conversations only, to the two member IDs
+-- in sorted order, so a pair of users always shares a single conversation.
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    direct_key TEXT UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    last_message_at TIMESTAMP NOT NULL
+);
+
+-- last_read_at is the member's read receipt: every message up to it has
+-- been read.
+CREATE TABLE conversation_members (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_at TIMESTAMP,
+    joined_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (conversation_id, user_id)
+);
+CREATE INDEX conversation_members_user_idx ON conversation_members (user_id);
+
+CREATE TABLE messages (
+    id UUID PRIMARY KEY,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX messages_conversation_idx ON messages (conversation_id, created_at DESC);
+
+-- Anyone may start a conversation with a user unless a row here says
+-- otherwise.
+CREATE TABLE message_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    allow_from TEXT NOT NULL CHECK (allow_from IN ('everyone', 'following'))
+);
+
+-- +goose Down
+DROP TABLE message_settings;
+DROP TABLE messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Mentions look usernames up case-insensitively, so two accounts can't
+-- share a username that differs only in case. Where they already do, the
+-- oldest account keeps it and the others get the start of their ID
+-- appended.
+UPDATE users
+SET username = username || '_' || left(id::text, 8),
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY lower(username) ORDER BY created_at, id
+        ) AS n
+        FROM users
+    ) ranked
+    WHERE n > 1
+);
+
+CREATE UNIQUE INDEX users_username_lower_key ON users (lower(username));
+
+-- +goose Down
+DROP INDEX users_username_lower_key;
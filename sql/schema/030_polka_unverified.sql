@@ -0,0 +1,12 @@
+-- +goose Up
+-- Events applied without an ID or signature can't be deduplicated, and are
+-- logged as such rather than as processed.
+ALTER TABLE polka_webhook_log DROP CONSTRAINT polka_webhook_log_status_check;
+ALTER TABLE polka_webhook_log ADD CONSTRAINT polka_webhook_log_status_check
+    CHECK (status IN ('processed', 'unverified-idempotency', 'ignored', 'duplicate', 'rejected', 'failed'));
+
+-- +goose Down
+UPDATE polka_webhook_log SET status = 'processed' WHERE status = 'unverified-idempotency';
+ALTER TABLE polka_webhook_log DROP CONSTRAINT polka_webhook_log_status_check;
+ALTER TABLE polka_webhook_log ADD CONSTRAINT polka_webhook_log_status_check
+    CHECK (status IN ('processed', 'ignored', 'duplicate', 'rejected', 'failed'));
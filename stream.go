@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/stream"
+)
+
+const (
+	eventChirpCreated = "chirp.created"
+	// eventChirpDeleted is also sent when a moderator hides a chirp.
+	// Rechirps deleted along with their original don't get their own
+	// event; clients should drop them when the original goes.
+	eventChirpDeleted = "chirp.deleted"
+	// eventResync tells a resuming client that events were missed and it
+	// should refetch instead of relying on the stream.
+	eventResync = "resync"
+
+	streamHeartbeatInterval = 15 * time.Second
+	// streamRefreshInterval is how often a connection reloads who the
+	// viewer follows, blocks and mutes.
+	streamRefreshInterval = time.Minute
+	streamRetryMillis     = 5000
+)
+
+// publishChirpCreated sends a new chirp to every stream and webhook. It is
+// rendered as an anonymous viewer would see it, so chirps from shadow-banned
+// authors aren't published at all.
+func (cfg *apiConfig) publishChirpCreated(ctx context.Context, dbChirp database.Chirp) {
+	chirps, err := cfg.expandChirps(ctx, uuid.Nil, []database.Chirp{dbChirp})
+	if err != nil {
+		log.Printf("Couldn't render chirp %s for streaming: %v", dbChirp.ID, err)
+		return
+	}
+	if len(chirps) == 0 {
+		return
+	}
+	cfg.publishEvent(ctx, stream.Event{
+		Type:   eventChirpCreated,
+		Author: dbChirp.UserID,
+		Chirps: chirpRefs(dbChirp),
+	}, chirps[0])
+	cfg.emitWebhookEvent(ctx, eventChirpCreated, []uuid.UUID{dbChirp.UserID}, chirps[0])
+}
+
+func (cfg *apiConfig) publishChirpDeleted(ctx context.Context, dbChirp database.Chirp) {
+	deleted := struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}{dbChirp.ID, dbChirp.UserID}
+	cfg.publishEvent(ctx, stream.Event{
+		Type:   eventChirpDeleted,
+		Author: dbChirp.UserID,
+		Chirps: chirpRefs(dbChirp),
+	}, deleted)
+	cfg.emitWebhookEvent(ctx, eventChirpDeleted, []uuid.UUID{dbChirp.UserID}, deleted)
+}
+
+// publishEvent fills in the event's data from payload and publishes it.
+func (cfg *apiConfig) publishEvent(ctx context.Context, e stream.Event, payload any) {
+	data, err := json.Marshal(payload)
+	if err == nil {
+		e.Data = data
+		err = cfg.broker.Publish(ctx, e)
+	}
+	if err != nil {
+		log.Printf("Couldn't publish %s event: %v", e.Type, err)
+	}
+}
+
+// chirpRefs is the chirp and the one it quotes or rechirps, so the event
+// reaches subscribers to either thread.
+func chirpRefs(chirp database.Chirp) []uuid.UUID {
+	refs := []uuid.UUID{chirp.ID}
+	if chirp.QuoteOf.Valid {
+		refs = append(refs, chirp.QuoteOf.UUID)
+	}
+	if chirp.RechirpOf.Valid {
+		refs = append(refs, chirp.RechirpOf.UUID)
+	}
+	return refs
+}
+
+func isChirpEvent(e stream.Event) bool {
+	return e.Type == eventChirpCreated || e.Type == eventChirpDeleted
+}
+
+// streamAudience is what a connection needs to know about its viewer to
+// filter events. It is swapped whole on refresh, because the filter runs on
+// the publishing goroutine.
+type streamAudience struct {
+	hidden    map[uuid.UUID]bool
+	following map[uuid.UUID]bool
+}
+
+func (cfg *apiConfig) loadStreamAudience(ctx context.Context, viewerID uuid.UUID, withFollowing bool) (*streamAudience, error) {
+	audience := &streamAudience{hidden: map[uuid.UUID]bool{}, following: map[uuid.UUID]bool{}}
+	if viewerID == uuid.Nil {
+		return audience, nil
+	}
+
+	hidden, err := cfg.db.GetBlockedOrMutedIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range hidden {
+		audience.hidden[id] = true
+	}
+
+	if withFollowing {
+		following, err := cfg.db.GetFolloweeIDs(ctx, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range following {
+			audience.following[id] = true
+		}
+	}
+	return audience, nil
+}
+
+// handlerStream is a Server-Sent Events feed of chirps being created and
+// deleted. author_id limits it to one author and following=true to the
+// accounts the caller follows. Reconnecting clients resume from
+// Last-Event-ID.
+func (cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	query := r.URL.Query()
+	authorID := uuid.Nil
+	if s := query.Get("author_id"); s != "" {
+		authorID, err = uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+	}
+	following := query.Get("following") == "true"
+	if following && viewerID == uuid.Nil {
+		respondWithError(w, http.StatusUnauthorized, "Log in to stream the accounts you follow", nil)
+		return
+	}
+
+	lastID := int64(0)
+	if s := r.Header.Get("Last-Event-ID"); s != "" || query.Get("last_event_id") != "" {
+		if s == "" {
+			s = query.Get("last_event_id")
+		}
+		lastID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil || lastID < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "Streaming isn't supported", nil)
+		return
+	}
+
+	initial, err := cfg.loadStreamAudience(r.Context(), viewerID, following)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start stream", err)
+		return
+	}
+	var audience atomic.Pointer[streamAudience]
+	audience.Store(initial)
+
+	filter := func(e stream.Event) bool {
+		a := audience.Load()
+		if !isChirpEvent(e) {
+			return false
+		}
+		if authorID != uuid.Nil && e.Author != authorID {
+			return false
+		}
+		if following && !a.following[e.Author] && e.Author != viewerID {
+			return false
+		}
+		return !a.hidden[e.Author]
+	}
+	sub, missed, complete := cfg.hub.Subscribe(lastID, filter)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventResync)
+	}
+	for _, e := range missed {
+		writeStreamEvent(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	refresh := time.NewTicker(streamRefreshInterval)
+	defer refresh.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind, or the server is shutting
+				// down; the client reconnects and resumes.
+				return
+			}
+			if err := writeStreamEvent(w, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-refresh.C:
+			if a, err := cfg.loadStreamAudience(r.Context(), viewerID, following); err == nil {
+				audience.Store(a)
+			}
+			continue
+		}
+		flusher.Flush()
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, e stream.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/entitlements"
+)
+
+const (
+	jobExpireSubscriptions      = "subscriptions.expire"
+	expireSubscriptionsInterval = 15 * time.Minute
+)
+
+func subscriptionOf(sub database.Subscription) entitlements.Subscription {
+	return entitlements.Subscription{
+		Plan:             sub.Plan,
+		Status:           sub.Status,
+		CurrentPeriodEnd: sub.CurrentPeriodEnd,
+	}
+}
+
+// userEntitlements returns what a user's plan lets them do right now. It
+// doesn't wait for the expiry job: a lapsed subscription stops counting as
+// soon as its period (and any grace) is over.
+func (cfg *apiConfig) userEntitlements(ctx context.Context, userID uuid.UUID) (entitlements.Entitlements, error) {
+	sub, err := cfg.db.GetSubscription(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.limitPolicy.For(entitlements.PlanFree), nil
+	}
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+	return cfg.limitPolicy.Resolve(subscriptionOf(sub), time.Now().UTC()), nil
+}
+
+// activateSubscription starts or renews a user's Red subscription. Without
+// an explicit period end from Polka, a renewal adds a period to whatever is
+// left of the current one. An explicit end never shortens a live period,
+// since Polka may deliver an old upgrade after a newer renewal.
+func activateSubscription(ctx context.Context, q *database.Queries, userID uuid.UUID, periodEnd *time.Time, now time.Time) error {
+	existing, err := q.GetSubscription(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	current := now
+	if existing.Status != entitlements.StatusExpired && existing.CurrentPeriodEnd.After(current) {
+		current = existing.CurrentPeriodEnd
+	}
+	end := current.Add(entitlements.Period)
+	if periodEnd != nil {
+		end = periodEnd.UTC()
+		if current.After(end) {
+			end = current
+		}
+	}
+
+	if _, err := q.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+		UserID:           userID,
+		Plan:             entitlements.PlanRed,
+		CurrentPeriodEnd: end,
+	}); err != nil {
+		return err
+	}
+	_, err = q.SetChirpyRed(ctx, database.SetChirpyRedParams{
+		ID:          userID,
+		IsChirpyRed: sql.NullBool{Bool: true, Valid: true},
+	})
+	return err
+}
+
+// jobExpireSubscriptions marks lapsed subscriptions expired and clears the
+// members' is_chirpy_red flag.
+func (cfg *apiConfig) jobExpireSubscriptions(ctx context.Context, _ json.RawMessage) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	now := time.Now().UTC()
+	userIDs, err := q.ExpireSubscriptions(ctx, database.ExpireSubscriptionsParams{
+		Now:         now,
+		GraceCutoff: now.Add(-entitlements.GracePeriod),
+	})
+	if err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		if _, err := q.SetChirpyRed(ctx, database.SetChirpyRedParams{
+			ID:          userID,
+			IsChirpyRed: sql.NullBool{Bool: false, Valid: true},
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if len(userIDs) > 0 {
+		log.Printf("Expired %d subscriptions", len(userIDs))
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerMySubscription(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Plan             string     `json:"plan"`
+		Status           string     `json:"status,omitempty"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end,omitempty"`
+		ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+		CanceledAt       *time.Time `json:"canceled_at,omitempty"`
+		Entitlements     planLimits `json:"entitlements"`
+	}
+
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	var resp response
+	sub, err := cfg.db.GetSubscription(r.Context(), userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		resp.Plan = entitlements.PlanFree
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve subscription", err)
+		return
+	default:
+		resp.Plan = sub.Plan
+		resp.Status = sub.Status
+		// The expiry job may not have caught up yet.
+		if !subscriptionOf(sub).ActiveAt(now) {
+			resp.Status = entitlements.StatusExpired
+		}
+		resp.CurrentPeriodEnd = &sub.CurrentPeriodEnd
+		if resp.Status != entitlements.StatusExpired {
+			expires := subscriptionOf(sub).ExpiresAt()
+			resp.ExpiresAt = &expires
+		}
+		if sub.CanceledAt.Valid {
+			resp.CanceledAt = &sub.CanceledAt.Time
+		}
+	}
+
+	resp.Entitlements = limitsOf(cfg.limitPolicy.Resolve(subscriptionOf(sub), now))
+	respondWithJSON(w, http.StatusOK, resp)
+}
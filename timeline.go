@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+)
+
+// timelineReader builds a user's home timeline: chirps from the accounts
+// they follow plus their own, newest first. Handlers only talk to this
+// interface so the storage strategy can change without touching them.
+type timelineReader interface {
+	HomeTimeline(ctx context.Context, userID uuid.UUID, before time.Time, limit int32) ([]database.Chirp, error)
+}
+
+// fanOutOnReadTimeline assembles the timeline at request time by joining
+// against the follow graph.
+type fanOutOnReadTimeline struct {
+	db *database.Queries
+}
+
+func (t fanOutOnReadTimeline) HomeTimeline(ctx context.Context, userID uuid.UUID, before time.Time, limit int32) ([]database.Chirp, error) {
+	return t.db.GetHomeTimeline(ctx, database.GetHomeTimelineParams{
+		ViewerID: userID,
+		Before:   before,
+		PageSize: limit,
+	})
+}
+
+// materializedTimeline reads from timeline_entries, which background jobs
+// fill in as chirps are posted (fan-out-on-write). Authors with more than
+// followerThreshold followers are not fanned out; their chirps are merged in
+// at read time instead so one post doesn't write millions of rows.
+type materializedTimeline struct {
+	db                *database.Queries
+	followerThreshold int64
+}
+
+func (t materializedTimeline) HomeTimeline(ctx context.Context, userID uuid.UUID, before time.Time, limit int32) ([]database.Chirp, error) {
+	entries, err := t.db.GetMaterializedTimeline(ctx, database.GetMaterializedTimelineParams{
+		ViewerID: userID,
+		Before:   before,
+		PageSize: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	highFollower, err := t.db.GetHighFollowerTimeline(ctx, database.GetHighFollowerTimelineParams{
+		ViewerID:          userID,
+		FollowerThreshold: t.followerThreshold,
+		Before:            before,
+		PageSize:          limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeTimelines(limit, entries, highFollower), nil
+}
+
+// mergeTimelines combines newest-first chirp slices, dropping duplicates
+// (an author who crossed the follower threshold can appear in both) and
+// truncating to limit.
+func mergeTimelines(limit int32, feeds ...[]database.Chirp) []database.Chirp {
+	seen := map[uuid.UUID]bool{}
+	merged := []database.Chirp{}
+	for _, feed := range feeds {
+		for _, chirp := range feed {
+			if seen[chirp.ID] {
+				continue
+			}
+			seen[chirp.ID] = true
+			merged = append(merged, chirp)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].ID.String() > merged[j].ID.String()
+		}
+		return merged[i].CreatedAt.After(merged[j].CreatedAt)
+	})
+
+	if int32(len(merged)) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+const (
+	jobFanOutChirp     = "timeline.fan_out"
+	jobBackfillFollow  = "timeline.backfill"
+	jobRemoveFollow    = "timeline.unfollow"
+	backfillMaxEntries = 200
+)
+
+type fanOutPayload struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+type followPayload struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+}
+
+// followerCount returns how many followers a user has according to the
+// trigger-maintained follower_counts table.
+func (cfg *apiConfig) followerCount(ctx context.Context, userID uuid.UUID) (int64, error) {
+	count, err := cfg.db.GetFollowerCount(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return count, err
+}
+
+func (cfg *apiConfig) jobFanOutChirp(ctx context.Context, payload json.RawMessage) error {
+	var p fanOutPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+
+	if err := cfg.db.AddOwnTimelineEntry(ctx, p.ChirpID); err != nil {
+		return err
+	}
+
+	chirp, err := cfg.db.GetChirpsByID(ctx, p.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted before we got to it; nothing to fan out.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	followers, err := cfg.followerCount(ctx, chirp.UserID)
+	if err != nil {
+		return err
+	}
+	if followers > cfg.followerThreshold {
+		return nil
+	}
+
+	_, err = cfg.db.FanOutChirp(ctx, p.ChirpID)
+	return err
+}
+
+func (cfg *apiConfig) jobBackfillFollow(ctx context.Context, payload json.RawMessage) error {
+	var p followPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+
+	followers, err := cfg.followerCount(ctx, p.FolloweeID)
+	if err != nil {
+		return err
+	}
+	if followers > cfg.followerThreshold {
+		return nil
+	}
+
+	_, err = cfg.db.BackfillTimeline(ctx, database.BackfillTimelineParams{
+		FollowerID: p.FollowerID,
+		FolloweeID: p.FolloweeID,
+		MaxEntries: backfillMaxEntries,
+	})
+	return err
+}
+
+func (cfg *apiConfig) jobRemoveFollow(ctx context.Context, payload json.RawMessage) error {
+	var p followPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	return cfg.db.RemoveTimelineEntriesByAuthor(ctx, database.RemoveTimelineEntriesByAuthorParams{
+		UserID:   p.FollowerID,
+		AuthorID: p.FolloweeID,
+	})
+}
+
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	before, limit, err := cursorPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	dbChirps, err := cfg.timeline.HomeTimeline(r.Context(), userID, before, limit)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve timeline", err)
+		return
+	}
+
+	chirps, err := cfg.expandChirps(r.Context(), userID, dbChirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve timeline", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirps)
+}
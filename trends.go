@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/entities"
+	"workspace/github.com/Benjysparks/chirpy/internal/trends"
+)
+
+const (
+	jobComputeTrends = "trends.compute"
+	trendsInterval   = 5 * time.Minute
+	trendsLimit      = 20
+	trendsMinCount   = 3
+)
+
+var trendWindows = []trends.Window{trends.Hour, trends.Day}
+
+// trendsCache holds the latest computed trends in memory. It is reloaded
+// from trending_tags once per compute interval, or sooner when a moderator
+// changes the suppression list.
+type trendsCache struct {
+	ttl time.Duration
+
+	mu        sync.RWMutex
+	byWindow  map[string][]trends.Trend
+	expiresAt time.Time
+}
+
+func newTrendsCache(ttl time.Duration) *trendsCache {
+	return &trendsCache{ttl: ttl}
+}
+
+func (c *trendsCache) get(ctx context.Context, db *database.Queries, window string) ([]trends.Trend, error) {
+	c.mu.RLock()
+	if time.Now().Before(c.expiresAt) {
+		found := c.byWindow[window]
+		c.mu.RUnlock()
+		return found, nil
+	}
+	c.mu.RUnlock()
+
+	rows, err := db.GetTrendingTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byWindow := map[string][]trends.Trend{}
+	for _, row := range rows {
+		byWindow[row.TimeWindow] = append(byWindow[row.TimeWindow], trends.Trend{
+			Tag:          row.Tag,
+			Score:        row.Score,
+			RecentCount:  row.RecentCount,
+			BaselineRate: row.BaselineRate,
+		})
+	}
+
+	c.mu.Lock()
+	c.byWindow = byWindow
+	c.expiresAt = time.Now().Add(c.ttl)
+	c.mu.Unlock()
+	return byWindow[window], nil
+}
+
+func (c *trendsCache) invalidate() {
+	c.mu.Lock()
+	c.expiresAt = time.Time{}
+	c.mu.Unlock()
+}
+
+// jobComputeTrends scores hashtag usage for every window and replaces the
+// stored trends.
+func (cfg *apiConfig) jobComputeTrends(ctx context.Context, _ json.RawMessage) error {
+	now := time.Now().UTC()
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := cfg.db.WithTx(tx)
+
+	for _, window := range trendWindows {
+		rows, err := q.GetHashtagCounts(ctx, database.GetHashtagCountsParams{
+			RecentSince:   now.Add(-window.Recent),
+			BaselineSince: now.Add(-window.Baseline),
+		})
+		if err != nil {
+			return err
+		}
+		counts := []trends.Count{}
+		for _, row := range rows {
+			counts = append(counts, trends.Count{Tag: row.Tag, Recent: row.RecentCount, Total: row.TotalCount})
+		}
+
+		if err := q.ClearTrendingTags(ctx, window.Name); err != nil {
+			return err
+		}
+		for i, trend := range trends.Score(counts, window, trendsMinCount, trendsLimit) {
+			err := q.AddTrendingTag(ctx, database.AddTrendingTagParams{
+				TimeWindow:   window.Name,
+				Rank:         int32(i + 1),
+				Tag:          trend.Tag,
+				Score:        trend.Score,
+				RecentCount:  trend.RecentCount,
+				BaselineRate: trend.BaselineRate,
+				ComputedAt:   now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) handlerTrends(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = trends.Hour.Name
+	}
+	if window != trends.Hour.Name && window != trends.Day.Name {
+		respondWithError(w, http.StatusBadRequest, "window must be \"hour\" or \"day\"", nil)
+		return
+	}
+
+	found, err := cfg.trends.get(r.Context(), cfg.db, window)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trends", err)
+		return
+	}
+	if found == nil {
+		found = []trends.Trend{}
+	}
+
+	respondWithJSON(w, http.StatusOK, found)
+}
+
+type SuppressedTag struct {
+	Tag          string     `json:"tag"`
+	Reason       string     `json:"reason"`
+	SuppressedBy *uuid.UUID `json:"suppressed_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerSuppressTag(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Tag    string `json:"tag"`
+		Reason string `json:"reason"`
+	}
+
+	moderator, ok := cfg.requireRole(w, r, roleModerator)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	tag := entities.NormalizeTag(strings.TrimSpace(params.Tag))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Tag is required", nil)
+		return
+	}
+
+	err := cfg.db.SuppressTag(r.Context(), database.SuppressTagParams{
+		Tag:          tag,
+		SuppressedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Reason:       params.Reason,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't suppress tag", err)
+		return
+	}
+	cfg.trends.invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerUnsuppressTag(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	removed, err := cfg.db.UnsuppressTag(r.Context(), entities.NormalizeTag(r.PathValue("tag")))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unsuppress tag", err)
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, http.StatusNotFound, "Tag is not suppressed", nil)
+		return
+	}
+	cfg.trends.invalidate()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerSuppressedTags(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator); !ok {
+		return
+	}
+
+	rows, err := cfg.db.GetSuppressedTags(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve suppressed tags", err)
+		return
+	}
+
+	tags := []SuppressedTag{}
+	for _, row := range rows {
+		tag := SuppressedTag{Tag: row.Tag, Reason: row.Reason, CreatedAt: row.CreatedAt}
+		if row.SuppressedBy.Valid {
+			tag.SuppressedBy = &row.SuppressedBy.UUID
+		}
+		tags = append(tags, tag)
+	}
+
+	respondWithJSON(w, http.StatusOK, tags)
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"workspace/github.com/Benjysparks/chirpy/internal/database"
+	"workspace/github.com/Benjysparks/chirpy/internal/jobs"
+	"workspace/github.com/Benjysparks/chirpy/internal/webhooks"
+)
+
+const (
+	// eventUserFollowed is only sent to webhooks; the chirp events are
+	// shared with the live streams.
+	eventUserFollowed = "user.followed"
+
+	jobDispatchWebhooks = "webhooks.dispatch"
+	jobDeliverWebhook   = "webhooks.deliver"
+
+	maxWebhooksPerUser = 10
+
+	deliveryPending   = "pending"
+	deliveryRetrying  = "retrying"
+	deliverySucceeded = "succeeded"
+	deliveryDead      = "dead"
+)
+
+var webhookEvents = []string{eventChirpCreated, eventChirpDeleted, eventUserFollowed}
+
+type Webhook struct {
+	ID     uuid.UUID `json:"id"`
+	URL    string    `json:"url"`
+	Events []string  `json:"events"`
+	Global bool      `json:"global"`
+	Active bool      `json:"active"`
+	// Secret is only shown when the webhook is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func webhookFromDB(hook database.Webhook) Webhook {
+	return Webhook{
+		ID:        hook.ID,
+		URL:       hook.Url,
+		Events:    hook.Events,
+		Global:    hook.Global,
+		Active:    hook.Active,
+		CreatedAt: hook.CreatedAt,
+		UpdatedAt: hook.UpdatedAt,
+	}
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID       `json:"id"`
+	EventID       uuid.UUID       `json:"event_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	// AttemptLog is only filled in when a single delivery is fetched.
+	AttemptLog []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+type WebhookAttempt struct {
+	AttemptedAt  time.Time `json:"attempted_at"`
+	StatusCode   *int32    `json:"status_code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body"`
+	DurationMs   int32     `json:"duration_ms"`
+}
+
+func webhookDeliveryFromDB(d database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:        d.ID,
+		EventID:   d.EventID,
+		Event:     d.Event,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+	if d.NextAttemptAt.Valid && (d.Status == deliveryPending || d.Status == deliveryRetrying) {
+		delivery.NextAttemptAt = &d.NextAttemptAt.Time
+	}
+	return delivery
+}
+
+// webhookEvent is the body every subscribed webhook receives. Its ID is
+// shared by all of the event's deliveries.
+type webhookEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// webhookDispatchPayload adds the users the event concerns, whose own
+// webhooks receive it alongside the global ones.
+type webhookDispatchPayload struct {
+	webhookEvent
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+type webhookDeliveryPayload struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// emitWebhookEvent queues an event for every webhook that subscribes to it.
+// Matching webhooks and creating deliveries happens in the background.
+func (cfg *apiConfig) emitWebhookEvent(ctx context.Context, event string, userIDs []uuid.UUID, data any) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	cfg.enqueue(ctx, jobDispatchWebhooks, webhookDispatchPayload{
+		webhookEvent: webhookEvent{
+			ID:        uuid.New(),
+			Event:     event,
+			CreatedAt: time.Now().UTC(),
+			Data:      dat,
+		},
+		UserIDs: userIDs,
+	}, jobs.Options{})
+}
+
+// jobDispatchWebhooks creates a delivery per subscribed webhook. A retry
+// after a partial failure doesn't duplicate deliveries, because each
+// webhook gets an event at most once.
+func (cfg *apiConfig) jobDispatchWebhooks(ctx context.Context, payload json.RawMessage) error {
+	var p webhookDispatchPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+
+	hooks, err := cfg.db.GetWebhooksForEvent(ctx, database.GetWebhooksForEventParams{
+		Event:   p.Event,
+		UserIds: p.UserIDs,
+	})
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(p.webhookEvent)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		delivery, err := cfg.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID: hook.ID,
+			EventID:   p.ID,
+			Event:     p.Event,
+			Payload:   body,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cfg.enqueue(ctx, jobDeliverWebhook, webhookDeliveryPayload{DeliveryID: delivery.ID}, jobs.Options{
+			DedupeKey: jobDeliverWebhook + ":" + delivery.ID.String() + ":0",
+		})
+	}
+	return nil
+}
+
+// jobDeliverWebhook makes one attempt at a delivery and schedules the next
+// one itself, so deliveries follow webhooks.Backoff rather than the job
+// queue's much shorter retries. Errors returned here are the database's,
+// and retrying the job may send the delivery twice; receivers can tell from
+// the delivery ID.
+func (cfg *apiConfig) jobDeliverWebhook(ctx context.Context, payload json.RawMessage) error {
+	var p webhookDeliveryPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+
+	d, err := cfg.db.GetWebhookDeliveryForSend(ctx, p.DeliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The webhook was deleted.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if d.Status == deliverySucceeded || d.Status == deliveryDead {
+		return nil
+	}
+	if !d.Active {
+		return cfg.db.UpdateWebhookDelivery(ctx, database.UpdateWebhookDeliveryParams{
+			ID:       d.ID,
+			Status:   deliveryDead,
+			Attempts: d.Attempts,
+		})
+	}
+
+	result, sendErr := cfg.webhookSender.Send(ctx, webhooks.Delivery{
+		ID:     d.ID.String(),
+		Event:  d.Event,
+		URL:    d.Url,
+		Secret: d.Secret,
+		Body:   d.Payload,
+	})
+	attempt := database.RecordWebhookAttemptParams{
+		DeliveryID:   d.ID,
+		StatusCode:   sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0},
+		ResponseBody: result.Body,
+		DurationMs:   int32(result.Duration.Milliseconds()),
+	}
+	if sendErr != nil {
+		attempt.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+	if err := cfg.db.RecordWebhookAttempt(ctx, attempt); err != nil {
+		return err
+	}
+
+	update := database.UpdateWebhookDeliveryParams{ID: d.ID, Attempts: d.Attempts + 1}
+	switch {
+	case sendErr == nil:
+		update.Status = deliverySucceeded
+	case int(update.Attempts) >= webhooks.MaxAttempts:
+		update.Status = deliveryDead
+	default:
+		update.Status = deliveryRetrying
+		next := time.Now().UTC().Add(webhooks.Backoff(int(update.Attempts)))
+		update.NextAttemptAt = sql.NullTime{Time: next, Valid: true}
+		err := cfg.jobs.Enqueue(ctx, jobDeliverWebhook, webhookDeliveryPayload{DeliveryID: d.ID}, jobs.Options{
+			// The running job holds the dedupe key of the current attempt.
+			DedupeKey: jobDeliverWebhook + ":" + d.ID.String() + ":" + fmt.Sprint(update.Attempts),
+			RunAt:     next,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return cfg.db.UpdateWebhookDelivery(ctx, update)
+}
+
+// webhookParams is the body of both creating and updating a webhook.
+type webhookParams struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Global bool     `json:"global"`
+	Active *bool    `json:"active"`
+}
+
+func (p *webhookParams) validate() string {
+	if err := webhooks.ValidURL(p.URL); err != nil {
+		return "Webhook URL must be a public http or https URL"
+	}
+	if len(p.Events) == 0 {
+		return "Choose at least one event"
+	}
+	events := []string{}
+	for _, event := range p.Events {
+		if !slices.Contains(webhookEvents, event) {
+			return "Unknown event " + event
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	p.Events = events
+	return ""
+}
+
+func (cfg *apiConfig) handlerCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user, err := cfg.authenticatedUser(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	params := webhookParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if msg := params.validate(); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg, nil)
+		return
+	}
+	if params.Global && user.Role != roleAdmin {
+		respondWithError(w, http.StatusForbidden, "Only admins can register global webhooks", nil)
+		return
+	}
+
+	count, err := cfg.db.CountWebhooks(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook", err)
+		return
+	}
+	if count >= maxWebhooksPerUser {
+		respondWithError(w, http.StatusConflict, "You have too many webhooks", nil)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook", err)
+		return
+	}
+	hook, err := cfg.db.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		UserID: user.ID,
+		Url:    params.URL,
+		Secret: secret,
+		Events: params.Events,
+		Global: params.Global,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create webhook", err)
+		return
+	}
+
+	resp := webhookFromDB(hook)
+	resp.Secret = hook.Secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) handlerWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return
+	}
+
+	rows, err := cfg.db.GetWebhooks(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve webhooks", err)
+		return
+	}
+	hooks := []Webhook{}
+	for _, row := range rows {
+		hooks = append(hooks, webhookFromDB(row))
+	}
+	respondWithJSON(w, http.StatusOK, hooks)
+}
+
+// ownWebhook loads the webhook in the path if it belongs to the caller,
+// writing the error response itself when it doesn't.
+func (cfg *apiConfig) ownWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid webhook ID", err)
+		return database.Webhook{}, false
+	}
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "JWT token not valid", err)
+		return database.Webhook{}, false
+	}
+
+	hook, err := cfg.db.GetWebhook(r.Context(), database.GetWebhookParams{ID: webhookID, UserID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find webhook", err)
+		return database.Webhook{}, false
+	}
+	return hook, true
+}
+
+func (cfg *apiConfig) handlerGetWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookFromDB(hook))
+}
+
+// handlerUpdateWebhook replaces the URL and events, and pauses or resumes
+// the webhook with active. Whether it is global can't change.
+func (cfg *apiConfig) handlerUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	params := webhookParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if msg := params.validate(); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg, nil)
+		return
+	}
+	active := hook.Active
+	if params.Active != nil {
+		active = *params.Active
+	}
+
+	hook, err := cfg.db.UpdateWebhook(r.Context(), database.UpdateWebhookParams{
+		ID:     hook.ID,
+		UserID: hook.UserID,
+		Url:    params.URL,
+		Events: params.Events,
+		Active: active,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update webhook", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookFromDB(hook))
+}
+
+func (cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.DeleteWebhook(r.Context(), database.DeleteWebhookParams{ID: hook.ID, UserID: hook.UserID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete webhook", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, err := offsetPage(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	rows, err := cfg.db.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve deliveries", err)
+		return
+	}
+	deliveries := []WebhookDelivery{}
+	for _, row := range rows {
+		deliveries = append(deliveries, webhookDeliveryFromDB(row))
+	}
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+func (cfg *apiConfig) handlerWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	row, err := cfg.db.GetWebhookDelivery(r.Context(), database.GetWebhookDeliveryParams{ID: deliveryID, WebhookID: hook.ID})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Couldn't find delivery", err)
+		return
+	}
+	attempts, err := cfg.db.GetWebhookDeliveryAttempts(r.Context(), row.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve delivery", err)
+		return
+	}
+
+	delivery := webhookDeliveryFromDB(row)
+	delivery.AttemptLog = []WebhookAttempt{}
+	for _, a := range attempts {
+		attempt := WebhookAttempt{
+			AttemptedAt:  a.AttemptedAt,
+			Error:        a.Error.String,
+			ResponseBody: a.ResponseBody,
+			DurationMs:   a.DurationMs,
+		}
+		if a.StatusCode.Valid {
+			attempt.StatusCode = &a.StatusCode.Int32
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+	respondWithJSON(w, http.StatusOK, delivery)
+}
+
+// handlerRedeliverWebhook sends a finished delivery again, with a fresh set
+// of attempts. Deliveries that are still being retried can't be redelivered.
+func (cfg *apiConfig) handlerRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	row, err := cfg.db.ResetWebhookDelivery(r.Context(), database.ResetWebhookDeliveryParams{ID: deliveryID, WebhookID: hook.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := cfg.db.GetWebhookDelivery(r.Context(), database.GetWebhookDeliveryParams{ID: deliveryID, WebhookID: hook.ID}); err != nil {
+			respondWithError(w, http.StatusNotFound, "Couldn't find delivery", err)
+			return
+		}
+		respondWithError(w, http.StatusConflict, "Delivery is still being attempted", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't redeliver", err)
+		return
+	}
+
+	err = cfg.jobs.Enqueue(r.Context(), jobDeliverWebhook, webhookDeliveryPayload{DeliveryID: row.ID}, jobs.Options{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't redeliver", err)
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, webhookDeliveryFromDB(row))
+}